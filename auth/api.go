@@ -5,12 +5,16 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/Emyrk/osrs-launcher/internal/metrics"
+	"github.com/Emyrk/osrs-launcher/internal/redirect"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
@@ -34,7 +38,9 @@ func (a *JagexAccountAuth) DisplayName(ctx context.Context, cfg *oauth2.Config,
 		return AccountDisplayName{}, fmt.Errorf("fetch accounts req: %w", err)
 	}
 	req.Header.Add("Accept", "application/json")
+	start := time.Now()
 	resp, err := cli.Do(req)
+	metrics.ObserveRequest("display_name", start, resp, err)
 	if err != nil {
 		return AccountDisplayName{}, fmt.Errorf("fetch display name: %w", err)
 	}
@@ -77,7 +83,9 @@ func (a *JagexAccountAuth) UserInfo(ctx context.Context, cfg *oauth2.Config) (Us
 	}
 	req.Header.Add("Accept", "application/json")
 
+	start := time.Now()
 	resp, err := cli.Do(req)
+	metrics.ObserveRequest("userinfo", start, resp, err)
 	if err != nil {
 		return UserInfo{}, fmt.Errorf("fetch accounts: %w", err)
 	}
@@ -91,28 +99,44 @@ func (a *JagexAccountAuth) UserInfo(ctx context.Context, cfg *oauth2.Config) (Us
 	return info, json.NewDecoder(resp.Body).Decode(&info)
 }
 
-func (a *JagexAccountAuth) AuthConsent(ctx context.Context, cfg *oauth2.Config) (string, <-chan struct{}, error) {
+// consentURL returns the url of the consent page and the state its redirect
+// carries back. prompt is "consent" to always show the page, "none" to never
+// show it, or empty to let Jagex skip the consent it remembers.
+func (a *JagexAccountAuth) consentURL(cfg *oauth2.Config, prompt string) (string, string, error) {
 	authURL, err := url.Parse(cfg.Endpoint.AuthURL)
 	if err != nil {
-		return "", nil, fmt.Errorf("parsing auth url: %w", err)
+		return "", "", fmt.Errorf("parsing auth url: %w", err)
 	}
 
 	state := randomState()
 	nonce := uuid.NewString()
+	a.GameNonce = nonce
 	vals := url.Values{
-		"client_id":     {"1fddee4e-b100-4f4e-b2b0-097f9088f9d2"},
+		"client_id":     {GameSessionClientID},
 		"response_type": {"id_token code"},
 		"scope":         {"openid offline"},
-		"prompt":        {"consent"},
 		"state":         {state},
 		"id_token_hint": {a.IDToken},
 		"nonce":         {nonce},
-		"redirect_uri":  {"http://localhost"},
+		"redirect_uri":  {consentRedirectURL},
+	}
+	if prompt != "" {
+		vals.Set("prompt", prompt)
 	}
 	authURL.RawQuery = vals.Encode()
+	return authURL.String(), state, nil
+}
 
-	//secondCfg := *cfg
-	//cfg.ClientID = "1fddee4e-b100-4f4e-b2b0-097f9088f9d2"
+// AuthConsent returns the consent url to open in a browser and listens on
+// port 80 for its redirect. When a prompt=none attempt needs the consent page
+// after all, the browser is sent on to it. The channel is closed once the
+// redirect arrived or failed.
+func (a *JagexAccountAuth) AuthConsent(ctx context.Context, cfg *oauth2.Config) (string, <-chan struct{}, error) {
+	prompt := a.consentPrompt()
+	consent, _, err := a.consentURL(cfg, prompt)
+	if err != nil {
+		return "", nil, err
+	}
 
 	srvCtx, cancel := context.WithCancelCause(ctx)
 	srv := http.Server{
@@ -132,6 +156,18 @@ func (a *JagexAccountAuth) AuthConsent(ctx context.Context, cfg *oauth2.Config)
 			errorMsg := r.URL.Query().Get("error")
 			errorDescription := r.URL.Query().Get("error_description")
 			errorURI := r.URL.Query().Get("error_uri")
+			if needsConsentPage(prompt, errorMsg) {
+				log.Info().Str("error", errorMsg).Msg("Consent is not remembered, showing the consent page")
+				prompt = "consent"
+				page, _, err := a.consentURL(cfg, prompt)
+				if err != nil {
+					cancel(err)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				http.Redirect(w, r, page, http.StatusFound)
+				return
+			}
 			if errorMsg != "" {
 				// Combine the errors into a single string if either is provided.
 				if errorDescription == "" && errorURI != "" {
@@ -152,6 +188,7 @@ func (a *JagexAccountAuth) AuthConsent(ctx context.Context, cfg *oauth2.Config)
 			newIDToken := strings.TrimSpace(r.URL.Query().Get("id_token"))
 
 			a.GameIDToken = newIDToken
+			a.ConsentGrantedAt = time.Now()
 			cancel(nil)
 		}),
 		BaseContext: func(_ net.Listener) context.Context {
@@ -169,7 +206,44 @@ func (a *JagexAccountAuth) AuthConsent(ctx context.Context, cfg *oauth2.Config)
 		}
 	}()
 
-	return authURL.String(), srvCtx.Done(), nil
+	return consent, srvCtx.Done(), nil
+}
+
+// PasteConsent is AuthConsent for machines without a browser or port 80: the
+// user opens the consent url anywhere and pastes the http://localhost/#...
+// url the page redirects to, which fails to load away from the launcher.
+func (a *JagexAccountAuth) PasteConsent(cfg *oauth2.Config, p Prompter) error {
+	prompt := a.consentPrompt()
+	for {
+		consent, state, err := a.consentURL(cfg, prompt)
+		if err != nil {
+			return err
+		}
+		err = p.ShowURL("Grant the launcher consent", consent)
+		if err != nil {
+			return fmt.Errorf("showing consent url: %w", err)
+		}
+		pasted, err := p.ReadRedirect("Input the http://localhost url the consent page redirected to")
+		if err != nil {
+			return fmt.Errorf("input: %w", err)
+		}
+		result, err := redirect.ParseConsent(pasted)
+		var loginErr *redirect.LoginError
+		if errors.As(err, &loginErr) && needsConsentPage(prompt, loginErr.Code) {
+			log.Info().Str("error", loginErr.Code).Msg("Consent is not remembered, showing the consent page")
+			prompt = "consent"
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("parsing consent redirect: %w", err)
+		}
+		if result.State != state {
+			return fmt.Errorf("the consent redirect is from another attempt, paste the url of the latest one")
+		}
+		a.GameIDToken = result.IDToken
+		a.ConsentGrantedAt = time.Now()
+		return nil
+	}
 }
 
 type jagexError struct {
@@ -181,18 +255,39 @@ type jagexError struct {
 	ID      string `json:"id"`
 }
 
+// ErrSessionInvalid is returned when Jagex rejects the game session. The
+// session is cleared, a new one needs a fresh consent.
+var ErrSessionInvalid = errors.New("session token invalid, deleting, and you must reauthenticate")
+
 type JagexCharacter struct {
 	AccountID   string `json:"accountId"`
 	DisplayName string `json:"displayName"`
 	UserHash    string `json:"userHash"`
 }
 
+// FindCharacter matches a character by display name or id. An empty query
+// matches the only character of single character accounts.
+func FindCharacter(chars []JagexCharacter, query string) (JagexCharacter, error) {
+	if query == "" {
+		if len(chars) == 1 {
+			return chars[0], nil
+		}
+		return JagexCharacter{}, fmt.Errorf("account has %d characters, one must be chosen", len(chars))
+	}
+	for _, c := range chars {
+		if c.AccountID == query || strings.EqualFold(c.DisplayName, query) {
+			return c, nil
+		}
+	}
+	return JagexCharacter{}, fmt.Errorf("no character %q on this account", query)
+}
+
 func (a *JagexAccountAuth) Accounts(ctx context.Context) error {
 	if a.Session == "" {
 		return fmt.Errorf("empty session, cannot fetch accounts")
 	}
 
-	cli := http.DefaultClient
+	cli := httpClient(ctx)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://auth.runescape.com/game-session/v1/accounts", nil)
 	if err != nil {
 		return fmt.Errorf("fetch accounts req: %w", err)
@@ -200,7 +295,9 @@ func (a *JagexAccountAuth) Accounts(ctx context.Context) error {
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Authorization", "Bearer "+a.Session)
 
+	start := time.Now()
 	resp, err := cli.Do(req)
+	metrics.ObserveRequest("accounts", start, resp, err)
 	if err != nil {
 		return fmt.Errorf("fetch characters: %w", err)
 	}
@@ -208,14 +305,15 @@ func (a *JagexAccountAuth) Accounts(ctx context.Context) error {
 
 	if resp.StatusCode == http.StatusUnauthorized {
 		a.Session = ""
-		return fmt.Errorf("session token invalid, deleting, and you must reauthenticate")
+		return ErrSessionInvalid
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		a.Session = ""
+		// Only a 401 says the session is bad, anything else may be Jagex
+		// having trouble.
 		var jError jagexError
 		json.NewDecoder(resp.Body).Decode(&jError)
-		return fmt.Errorf("fetching characters failed (status %d), going to delete the session :: %s", resp.StatusCode, jError.Message)
+		return fmt.Errorf("fetching characters failed (status %d) :: %s", resp.StatusCode, jError.Message)
 	}
 
 	var accts []JagexCharacter
@@ -241,9 +339,7 @@ type sessionResponse struct {
 }
 
 func (a *JagexAccountAuth) Sessions(ctx context.Context, cfg *oauth2.Config) error {
-	cli := cfg.Client(ctx, &a.Token)
-
-	cli = &http.Client{}
+	cli := httpClient(ctx)
 	var buf bytes.Buffer
 	err := json.NewEncoder(&buf).Encode(sessionsPayload{
 		IDToken: a.GameIDToken,
@@ -259,7 +355,9 @@ func (a *JagexAccountAuth) Sessions(ctx context.Context, cfg *oauth2.Config) err
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
+	start := time.Now()
 	resp, err := cli.Do(req)
+	metrics.ObserveRequest("sessions", start, resp, err)
 	if err != nil {
 		return fmt.Errorf("fetch rsn: %w", err)
 	}
@@ -278,6 +376,7 @@ func (a *JagexAccountAuth) Sessions(ctx context.Context, cfg *oauth2.Config) err
 
 	// Always delete the now used id token
 	a.GameIDToken = ""
+	a.GameNonce = ""
 
 	var session sessionResponse
 	err = json.NewDecoder(resp.Body).Decode(&session)
@@ -292,3 +391,34 @@ func (a *JagexAccountAuth) Sessions(ctx context.Context, cfg *oauth2.Config) err
 	a.Session = session.SessionID
 	return nil
 }
+
+// ErrConsentRequired is returned when a game session cannot be created
+// without the user granting consent in a browser.
+var ErrConsentRequired = fmt.Errorf("game session consent required, run auth for this account")
+
+// EnsureSession refreshes the OAuth token, creates a game session if one is
+// missing and validates it by fetching the characters. It never prompts, so
+// it returns ErrConsentRequired when a session cannot be created without the
+// user.
+func (a *JagexAccountAuth) EnsureSession(ctx context.Context, cfg *oauth2.Config) error {
+	err := a.Refresh(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("refresh token: %w", err)
+	}
+
+	if a.Session == "" {
+		if a.GameIDToken == "" {
+			return ErrConsentRequired
+		}
+		err = a.Sessions(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("getting sessions: %w", err)
+		}
+	}
+
+	err = a.Accounts(ctx)
+	if err != nil {
+		return fmt.Errorf("validating session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/coreos/go-oidc"
+)
+
+// GameSessionClientID is the client the game session ID token is issued to,
+// it is separate from the launcher's client and needs its own consent.
+const GameSessionClientID = "1fddee4e-b100-4f4e-b2b0-097f9088f9d2"
+
+const consentRedirectURL = "http://localhost"
+
+// NeedsConsent reports whether the consent step has to run to create a game
+// session. It is not needed with a session or an unused game ID token, or when
+// the launcher's ID token was also issued to the game session client, which
+// then becomes the game ID token.
+func (a *JagexAccountAuth) NeedsConsent(idToken *oidc.IDToken) bool {
+	if a.Session != "" || a.GameIDToken != "" {
+		return false
+	}
+	if idToken != nil && slices.Contains(idToken.Audience, GameSessionClientID) {
+		// Verified as the launcher's ID token already.
+		a.GameIDToken = a.IDToken
+		a.GameNonce = ""
+		return false
+	}
+	return true
+}
+
+// consentPrompt forces the consent page until consent was granted once.
+// After that the browser first goes to the page with prompt=none, which
+// redirects straight back when Jagex remembers the consent and with an error
+// from needsConsentPage when it does not.
+func (a *JagexAccountAuth) consentPrompt() string {
+	if a.ConsentGrantedAt.IsZero() {
+		return "consent"
+	}
+	return "none"
+}
+
+// needsConsentPage reports whether a prompt=none consent redirected with an
+// error that the consent page itself resolves.
+func needsConsentPage(prompt string, errCode string) bool {
+	if prompt != "none" {
+		return false
+	}
+	switch errCode {
+	case "consent_required", "login_required", "interaction_required":
+		return true
+	}
+	return false
+}
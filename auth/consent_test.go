@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc"
+)
+
+func TestNeedsConsent(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     JagexAccountAuth
+		audience []string
+		want     bool
+		game     string
+	}{
+		{name: "no session", auth: JagexAccountAuth{IDToken: "id"}, audience: []string{LauncherClientID}, want: true},
+		{name: "session", auth: JagexAccountAuth{IDToken: "id", Session: "s"}, want: false},
+		{name: "unused game token", auth: JagexAccountAuth{IDToken: "id", GameIDToken: "game"}, want: false, game: "game"},
+		{name: "token for both clients", auth: JagexAccountAuth{IDToken: "id", GameNonce: "n"},
+			audience: []string{LauncherClientID, GameSessionClientID}, want: false, game: "id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.auth
+			got := a.NeedsConsent(&oidc.IDToken{Audience: tt.audience})
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if a.GameIDToken != tt.game {
+				t.Errorf("got game id token %q, want %q", a.GameIDToken, tt.game)
+			}
+			if tt.game == a.IDToken && a.GameNonce != "" {
+				t.Error("the launcher's token kept the nonce of a consent request")
+			}
+		})
+	}
+}
+
+func TestConsentURLPrompt(t *testing.T) {
+	cfg := JagexOAuthConfig()
+	a := &JagexAccountAuth{IDToken: "id"}
+	for _, granted := range []time.Time{{}, time.Now()} {
+		a.ConsentGrantedAt = granted
+		raw, state, err := a.consentURL(cfg, a.consentPrompt())
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		want := "consent"
+		if !granted.IsZero() {
+			want = "none"
+		}
+		if q.Get("prompt") != want {
+			t.Errorf("granted at %v: got prompt %q, want %q", granted, q.Get("prompt"), want)
+		}
+		if q.Get("client_id") != GameSessionClientID || q.Get("state") != state || q.Get("nonce") != a.GameNonce || a.GameNonce == "" {
+			t.Errorf("unexpected consent url %s", u)
+		}
+	}
+}
+
+func TestNeedsConsentPage(t *testing.T) {
+	tests := []struct {
+		prompt string
+		err    string
+		want   bool
+	}{
+		{prompt: "none", err: "consent_required", want: true},
+		{prompt: "none", err: "login_required", want: true},
+		{prompt: "none", err: "interaction_required", want: true},
+		{prompt: "none", err: "access_denied", want: false},
+		{prompt: "none", err: "", want: false},
+		{prompt: "consent", err: "consent_required", want: false},
+	}
+	for _, tt := range tests {
+		got := needsConsentPage(tt.prompt, tt.err)
+		if got != tt.want {
+			t.Errorf("prompt=%s error=%q: got %v, want %v", tt.prompt, tt.err, got, tt.want)
+		}
+	}
+}
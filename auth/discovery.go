@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/internal/metrics"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// JagexIssuer is the issuer of Jagex's tokens and the base of its OpenID
+// configuration.
+const JagexIssuer = "https://account.jagex.com/"
+
+const (
+	discoveryFile = "discovery.json"
+	jwksFile      = "jwks.json"
+	// defaultMaxAge is how long responses without Cache-Control are kept.
+	defaultMaxAge = time.Hour
+	// minKeyRefresh limits refetching the keys for unknown key IDs, tokens
+	// with made up IDs must not make the launcher hammer Jagex.
+	minKeyRefresh = time.Minute
+)
+
+// Discovery is the part of the OpenID configuration the launcher uses.
+type Discovery struct {
+	Issuer              string   `json:"issuer"`
+	AuthURL             string   `json:"authorization_endpoint"`
+	TokenURL            string   `json:"token_endpoint"`
+	UserInfoURL         string   `json:"userinfo_endpoint"`
+	JWKSURL             string   `json:"jwks_uri"`
+	SigningAlgorithms   []string `json:"id_token_signing_alg_values_supported"`
+	SupportedScopes     []string `json:"scopes_supported"`
+	SupportedPrompts    []string `json:"prompt_values_supported,omitempty"`
+	SupportedGrantTypes []string `json:"grant_types_supported"`
+}
+
+// Provider is Jagex's OpenID configuration with its signing keys, both
+// cached on disk.
+type Provider struct {
+	Discovery Discovery
+	Keys      *CachedKeySet
+}
+
+// cacheEntry is a cached response and until when it is fresh.
+type cacheEntry struct {
+	URL     string          `json:"url"`
+	Expires time.Time       `json:"expires"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// https://account.jagex.com/.well-known/openid-configuration
+//
+// JagexProvider loads the OpenID configuration from cacheDir while it is
+// fresh per the response's Cache-Control, and fetches it with ctx otherwise.
+// A stale copy is used when Jagex cannot be reached, so tokens can be
+// verified offline.
+func JagexProvider(ctx context.Context, cacheDir string) (*Provider, error) {
+	var d Discovery
+	err := cachedFetch(ctx, filepath.Join(cacheDir, discoveryFile), JagexIssuer+".well-known/openid-configuration", false, &d)
+	if err != nil {
+		return nil, fmt.Errorf("openid configuration: %w", err)
+	}
+	if d.Issuer != JagexIssuer {
+		return nil, fmt.Errorf("openid configuration is for issuer %q, expected %q", d.Issuer, JagexIssuer)
+	}
+	if d.JWKSURL == "" {
+		return nil, fmt.Errorf("openid configuration has no jwks_uri")
+	}
+	return &Provider{
+		Discovery: d,
+		Keys:      &CachedKeySet{URL: d.JWKSURL, Path: filepath.Join(cacheDir, jwksFile)},
+	}, nil
+}
+
+// CachedKeySet is an oidc.KeySet kept in a file. Keys are refetched when
+// the cache expires, or when a token is signed with a key it does not have
+// because Jagex rotated its keys.
+type CachedKeySet struct {
+	URL  string
+	Path string
+
+	mu          sync.Mutex
+	keys        *jose.JSONWebKeySet
+	lastRefresh time.Time
+}
+
+// ReadCachedKeySet returns the keys cached in cacheDir, without the network.
+// It returns an os.IsNotExist error when nothing is cached.
+func ReadCachedKeySet(cacheDir string) (*jose.JSONWebKeySet, error) {
+	var entry cacheEntry
+	err := readCache(filepath.Join(cacheDir, jwksFile), &entry)
+	if err != nil {
+		return nil, err
+	}
+	var keys jose.JSONWebKeySet
+	return &keys, json.Unmarshal(entry.Body, &keys)
+}
+
+// VerifySignature implements oidc.KeySet.
+func (k *CachedKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt: %w", err)
+	}
+	if len(jws.Signatures) == 0 {
+		return nil, fmt.Errorf("jwt has no signature")
+	}
+	kid := jws.Signatures[0].Header.KeyID
+
+	keys, err := k.load(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if kid != "" && len(keys.Key(kid)) == 0 {
+		log.Info().Str("kid", kid).Msg("token signed with an unknown key, refreshing keys")
+		keys, err = k.load(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	candidates := keys.Keys
+	if kid != "" {
+		candidates = keys.Key(kid)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no key with id %q", kid)
+	}
+	for _, key := range candidates {
+		payload, err := jws.Verify(key)
+		if err == nil {
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to verify signature with key %q", kid)
+}
+
+// load returns the keys, from memory, the cache file or Jagex. force skips
+// the cache, at most once per minRefresh.
+func (k *CachedKeySet) load(ctx context.Context, force bool) (*jose.JSONWebKeySet, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if force && time.Since(k.lastRefresh) < minKeyRefresh {
+		force = false
+	}
+	if k.keys != nil && !force {
+		return k.keys, nil
+	}
+	if force {
+		k.lastRefresh = time.Now()
+	}
+	var keys jose.JSONWebKeySet
+	err := cachedFetch(ctx, k.Path, k.URL, force, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	k.keys = &keys
+	return k.keys, nil
+}
+
+// cachedFetch decodes the JSON at url into v, using the copy at path while it
+// is fresh. force refetches a fresh copy. A failed fetch falls back to a stale
+// copy.
+func cachedFetch(ctx context.Context, path, url string, force bool, v interface{}) error {
+	var cached cacheEntry
+	err := readCache(path, &cached)
+	haveCache := err == nil && cached.URL == url
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("path", path).Msg("ignoring unreadable cache")
+	}
+	if haveCache && !force && time.Now().Before(cached.Expires) {
+		return json.Unmarshal(cached.Body, v)
+	}
+
+	entry, err := fetch(ctx, url)
+	if err != nil {
+		if haveCache && ctx.Err() == nil {
+			log.Warn().Err(err).Str("url", url).Msg("using a stale cached copy")
+			return json.Unmarshal(cached.Body, v)
+		}
+		return err
+	}
+	err = json.Unmarshal(entry.Body, v)
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", url, err)
+	}
+	err = writeCache(path, entry)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("cannot cache response")
+	}
+	return nil
+}
+
+func fetch(ctx context.Context, url string) (cacheEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	req.Header.Add("Accept", "application/json")
+	start := time.Now()
+	resp, err := httpClient(ctx).Do(req)
+	metrics.ObserveRequest("discovery", start, resp, err)
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return cacheEntry{}, fmt.Errorf("fetch %s: status code: %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	if !json.Valid(body) {
+		return cacheEntry{}, fmt.Errorf("fetch %s: response is not json", url)
+	}
+	return cacheEntry{URL: url, Expires: start.Add(maxAge(resp.Header)), Body: body}, nil
+}
+
+// maxAge is how long a response may be cached per its Cache-Control or
+// Expires headers.
+func maxAge(h http.Header) time.Duration {
+	if cc := h.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch {
+			case directive == "no-store", directive == "no-cache":
+				// Still written to disk as the offline fallback, but never
+				// fresh.
+				return 0
+			case strings.HasPrefix(directive, "max-age="):
+				seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+				if err != nil || seconds < 0 {
+					continue
+				}
+				age, _ := strconv.Atoi(h.Get("Age"))
+				if age > seconds {
+					return 0
+				}
+				return time.Duration(seconds-age) * time.Second
+			}
+		}
+	}
+	if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+		if d := time.Until(expires); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultMaxAge
+}
+
+func readCache(path string, entry *cacheEntry) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, entry)
+}
+
+func writeCache(path string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
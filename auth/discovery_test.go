@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMaxAge(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{name: "default", want: defaultMaxAge},
+		{name: "max-age", header: map[string]string{"Cache-Control": "public, max-age=600"}, want: 10 * time.Minute},
+		{name: "max-age minus age", header: map[string]string{"Cache-Control": "max-age=600", "Age": "100"}, want: 500 * time.Second},
+		{name: "older than max-age", header: map[string]string{"Cache-Control": "max-age=600", "Age": "900"}, want: 0},
+		{name: "upper case", header: map[string]string{"Cache-Control": "MAX-AGE=60"}, want: time.Minute},
+		{name: "no-store", header: map[string]string{"Cache-Control": "no-store, max-age=600"}, want: 0},
+		{name: "no-cache", header: map[string]string{"Cache-Control": "no-cache"}, want: 0},
+		{name: "bad max-age", header: map[string]string{"Cache-Control": "max-age=soon"}, want: defaultMaxAge},
+		{name: "expired", header: map[string]string{"Expires": "Mon, 02 Jan 2006 15:04:05 GMT"}, want: 0},
+		{name: "max-age wins over expires", header: map[string]string{"Cache-Control": "max-age=60", "Expires": "Mon, 02 Jan 2006 15:04:05 GMT"}, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := make(http.Header)
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			if got := maxAge(h); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	h := make(http.Header)
+	h.Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if got := maxAge(h); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("got %s for an hour in the future", got)
+	}
+}
+
+func TestCachedFetch(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write([]byte(`{"issuer":"` + JagexIssuer + `"}`))
+	}))
+	path := filepath.Join(t.TempDir(), discoveryFile)
+	ctx := context.Background()
+
+	var d Discovery
+	err := cachedFetch(ctx, path, srv.URL, false, &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Issuer != JagexIssuer || requests != 1 {
+		t.Fatalf("got %+v after %d requests", d, requests)
+	}
+
+	// No-cache responses are refetched, and only used while offline.
+	srv.Close()
+	d = Discovery{}
+	err = cachedFetch(ctx, path, srv.URL, false, &d)
+	if err != nil {
+		t.Fatalf("stale copy not used: %v", err)
+	}
+	if d.Issuer != JagexIssuer {
+		t.Errorf("got %+v from the stale copy", d)
+	}
+
+	err = cachedFetch(ctx, path, srv.URL+"/other", false, &d)
+	if err == nil {
+		t.Error("used the cache of another url")
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// SignatureStatus is how a token's signature checked out against the cached
+// keys.
+type SignatureStatus string
+
+const (
+	SignatureValid SignatureStatus = "valid"
+	// SignatureInvalid means a key with the token's ID exists but does not
+	// verify the token, it was tampered with or the key was replaced.
+	SignatureInvalid SignatureStatus = "invalid"
+	// SignatureUnknownKey means the cached keys do not have the token's key,
+	// they may be older than the token.
+	SignatureUnknownKey SignatureStatus = "unknown_key"
+	// SignatureNoKeys means there are no cached keys to check against.
+	SignatureNoKeys SignatureStatus = "no_keys"
+	// SignatureOpaque is a token that is not a JWT, only Jagex can check it.
+	SignatureOpaque SignatureStatus = "opaque"
+)
+
+// TokenInfo is what a token says about itself, decoded without the network.
+type TokenInfo struct {
+	Name      string          `json:"name"`
+	Present   bool            `json:"present"`
+	Algorithm string          `json:"alg,omitempty"`
+	KeyID     string          `json:"kid,omitempty"`
+	Issuer    string          `json:"iss,omitempty"`
+	Audience  []string        `json:"aud,omitempty"`
+	Subject   string          `json:"sub,omitempty"`
+	AMR       []string        `json:"amr,omitempty"`
+	AZP       string          `json:"azp,omitempty"`
+	Nonce     string          `json:"nonce,omitempty"`
+	IssuedAt  *time.Time      `json:"iat,omitempty"`
+	Expiry    *time.Time      `json:"exp,omitempty"`
+	AuthTime  *time.Time      `json:"auth_time,omitempty"`
+	Signature SignatureStatus `json:"signature,omitempty"`
+	// Error is why the token could not be decoded.
+	Error string `json:"error,omitempty"`
+}
+
+// Expired reports whether the token is past its expiry at t.
+func (i TokenInfo) Expired(t time.Time) bool {
+	return i.Expiry != nil && t.After(*i.Expiry)
+}
+
+// Inspect decodes the account's ID token, game ID token and access token.
+// keys are the cached Jagex signing keys, nil when there are none.
+func (a JagexAccountAuth) Inspect(keys *jose.JSONWebKeySet) []TokenInfo {
+	return []TokenInfo{
+		InspectToken("id_token", a.IDToken, keys),
+		InspectToken("game_id_token", a.GameIDToken, keys),
+		InspectToken("access_token", a.Token.AccessToken, keys),
+	}
+}
+
+// InspectToken decodes a token and checks its signature against keys.
+func InspectToken(name, raw string, keys *jose.JSONWebKeySet) TokenInfo {
+	info := TokenInfo{Name: name, Present: raw != ""}
+	if raw == "" {
+		return info
+	}
+	if strings.Count(raw, ".") != 2 {
+		info.Signature = SignatureOpaque
+		return info
+	}
+
+	jws, err := jose.ParseSigned(raw)
+	if err != nil {
+		info.Error = fmt.Sprintf("parsing jwt: %s", err)
+		return info
+	}
+	if len(jws.Signatures) > 0 {
+		info.Algorithm = jws.Signatures[0].Header.Algorithm
+		info.KeyID = jws.Signatures[0].Header.KeyID
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(raw, ".")[1])
+	if err != nil {
+		info.Error = fmt.Sprintf("decoding claims: %s", err)
+		return info
+	}
+	var claims struct {
+		Issuer   string   `json:"iss"`
+		Audience audience `json:"aud"`
+		Subject  string   `json:"sub"`
+		AMR      []string `json:"amr"`
+		AZP      string   `json:"azp"`
+		Nonce    string   `json:"nonce"`
+		IssuedAt int64    `json:"iat"`
+		Expiry   int64    `json:"exp"`
+		AuthTime int64    `json:"auth_time"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		info.Error = fmt.Sprintf("decoding claims: %s", err)
+		return info
+	}
+	info.Issuer = claims.Issuer
+	info.Audience = claims.Audience
+	info.Subject = claims.Subject
+	info.AMR = claims.AMR
+	info.AZP = claims.AZP
+	info.Nonce = claims.Nonce
+	info.IssuedAt = unixTime(claims.IssuedAt)
+	info.Expiry = unixTime(claims.Expiry)
+	info.AuthTime = unixTime(claims.AuthTime)
+	info.Signature = verifySignature(jws, info.KeyID, keys)
+	return info
+}
+
+func verifySignature(jws *jose.JSONWebSignature, kid string, keys *jose.JSONWebKeySet) SignatureStatus {
+	if keys == nil || len(keys.Keys) == 0 {
+		return SignatureNoKeys
+	}
+	candidates := keys.Keys
+	if kid != "" {
+		candidates = keys.Key(kid)
+	}
+	if len(candidates) == 0 {
+		return SignatureUnknownKey
+	}
+	for _, key := range candidates {
+		if _, err := jws.Verify(key.Public()); err == nil {
+			return SignatureValid
+		}
+	}
+	return SignatureInvalid
+}
+
+func unixTime(sec int64) *time.Time {
+	if sec == 0 {
+		return nil
+	}
+	t := time.Unix(sec, 0)
+	return &t
+}
+
+// audience is the aud claim, a string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	err := json.Unmarshal(data, &list)
+	*a = list
+	return err
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"strings"
+	"net/http"
 	"time"
 
-	"github.com/charmbracelet/huh"
+	"github.com/Emyrk/osrs-launcher/internal/metrics"
+	"github.com/Emyrk/osrs-launcher/internal/redirect"
 	"github.com/coreos/go-oidc"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
@@ -20,20 +22,41 @@ type JagexAccountAuth struct {
 	GameIDToken string           `json:"game_id_token"`
 	Session     string           `json:"session"`
 	Characters  []JagexCharacter `json:"characters"`
+	// GameNonce is the nonce of the consent request GameIDToken answers.
+	GameNonce string `json:"game_nonce,omitempty"`
+	// ConsentGrantedAt is when the game session client was last given an ID
+	// token, after which Jagex remembers the consent.
+	ConsentGrantedAt time.Time `json:"consent_granted_at,omitempty"`
 }
 
 func (a *JagexAccountAuth) Refresh(ctx context.Context, cfg *oauth2.Config) error {
 	before := a.Token.AccessToken
+	valid := a.Token.Valid()
+	start := time.Now()
 	token, err := cfg.TokenSource(ctx, &a.Token).Token()
 	if err != nil {
+		metrics.TokenRefreshes.WithLabelValues("failed").Inc()
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			metrics.ObserveRequest("token", start, retrieveErr.Response, nil)
+		} else {
+			metrics.ObserveRequest("token", start, nil, err)
+		}
 		return fmt.Errorf("refresh: %w", err)
 	}
+	// A still valid token is returned as is, without asking Jagex.
+	if !valid {
+		metrics.ObserveRequest("token", start, &http.Response{StatusCode: http.StatusOK}, nil)
+	}
 
 	after := token.AccessToken
 	idToken := a.IDToken
 	if before != after {
 		idToken = token.Extra("id_token").(string)
+		metrics.TokenRefreshes.WithLabelValues("refreshed").Inc()
 		log.Info().Msg("token refreshed")
+	} else if !valid {
+		metrics.TokenRefreshes.WithLabelValues("unchanged").Inc()
 	}
 
 	a.Token = *token
@@ -54,38 +77,57 @@ func (a JagexAccountAuth) VerifyAll(ctx context.Context, verifier *oidc.IDTokenV
 	return idToken, nil
 }
 
+// VerifyIDToken verifies the launcher's ID token: its signature and expiry,
+// that it was issued by Jagex to the launcher, and that it belongs to the
+// saved access token.
 func (a JagexAccountAuth) VerifyIDToken(ctx context.Context, verifier *oidc.IDTokenVerifier) (*oidc.IDToken, error) {
 	parsed, err := verifier.Verify(ctx, a.IDToken)
 	if err != nil {
 		return nil, fmt.Errorf("verify: %w", err)
 	}
+	err = checkClaims(parsed, LauncherClientID, "")
+	if err != nil {
+		return nil, err
+	}
 
-	if strings.Contains(a.IDToken, "com_jagex_auth_desktop_launcher") {
+	if parsed.AccessTokenHash != "" {
 		err = parsed.VerifyAccessToken(a.Token.AccessToken)
 		if err != nil {
-			return nil, fmt.Errorf("invalid access token: %w", err)
+			return nil, fmt.Errorf("%w: %s", ErrAccessTokenHash, err)
 		}
 	}
 
 	return parsed, nil
 }
 
-// https://account.jagex.com/.well-known/openid-configuration
-func JagexProvider() (*oidc.Provider, error) {
-	return oidc.NewProvider(context.Background(), "https://account.jagex.com/")
+// VerifyGameIDToken verifies the ID token the consent step got for the game
+// session client, including that it answers the consent request.
+func (a JagexAccountAuth) VerifyGameIDToken(ctx context.Context, verifier *oidc.IDTokenVerifier) (*oidc.IDToken, error) {
+	parsed, err := verifier.Verify(ctx, a.GameIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify: %w", err)
+	}
+	err = checkClaims(parsed, GameSessionClientID, a.GameNonce)
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
 }
 
-// JagexVerifier is missing the jagex keyset, Idk where to get it.
-func JagexVerifier(provider *oidc.Provider) *oidc.IDTokenVerifier {
-	return provider.Verifier(&oidc.Config{
-		ClientID:          "com_jagex_auth_desktop_launcher",
-		SkipClientIDCheck: true,
+// JagexVerifier verifies ID tokens' signatures and expiry with the provider's
+// cached keys. It serves both clients, so the audience and issuer are checked
+// by VerifyIDToken and VerifyGameIDToken.
+func JagexVerifier(provider *Provider) *oidc.IDTokenVerifier {
+	return oidc.NewVerifier(provider.Discovery.Issuer, provider.Keys, &oidc.Config{
+		SkipClientIDCheck:    true,
+		SkipIssuerCheck:      true,
+		SupportedSigningAlgs: provider.Discovery.SigningAlgorithms,
 	})
 }
 
 func JagexOAuthConfig() *oauth2.Config {
 	return &oauth2.Config{
-		ClientID:     "com_jagex_auth_desktop_launcher",
+		ClientID:     LauncherClientID,
 		ClientSecret: "",
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  "https://account.jagex.com/oauth2/auth",
@@ -98,56 +140,74 @@ func JagexOAuthConfig() *oauth2.Config {
 	}
 }
 
+// LoginIntent is the intent of the jagex: redirect, how the login page asks
+// the launcher to continue. It does not tell which provider the account used,
+// the login page sends social_auth after password logins as well, so every
+// known intent continues the same way and only unknown ones are rejected.
+type LoginIntent string
+
+const (
+	// IntentLogin is a redirect without an intent, like the https
+	// launcher-redirect form.
+	IntentLogin LoginIntent = ""
+	// IntentSocialAuth is what the launcher's login page sends once the
+	// account logged in.
+	IntentSocialAuth LoginIntent = "social_auth"
+)
+
+// LoginOptions tune the login page.
+type LoginOptions struct {
+	// LoginHint pre-fills the login page with an email or user ID.
+	LoginHint string
+}
+
 // Look at 	"static navigateToAuthConsent(origin: string, id_token: string, nonce: string) {"
-func AuthenticateJagexAccount(ctx context.Context, cfg *oauth2.Config) (*JagexAccountAuth, error) {
+func AuthenticateJagexAccount(ctx context.Context, cfg *oauth2.Config, p Prompter, opts LoginOptions) (*JagexAccountAuth, error) {
 	verifier := oauth2.GenerateVerifier()
 
 	// https://github.com/Adamcake/Bolt/blob/master/app/src/lib/Services/AuthService.ts#L34-L46
-	u := cfg.AuthCodeURL(randomState(),
+	params := []oauth2.AuthCodeOption{
 		oauth2.AccessTypeOffline,
 		oauth2.S256ChallengeOption(verifier),
 
 		// Taken from pcap
 		oauth2.SetAuthURLParam("prompt", "login"),
 		oauth2.SetAuthURLParam("flow", "launcher"),
-	)
+	}
+	if opts.LoginHint != "" {
+		params = append(params, oauth2.SetAuthURLParam("login_hint", opts.LoginHint))
+	}
+	state := randomState()
+	u := cfg.AuthCodeURL(state, params...)
 
-	fmt.Println("Visit this url")
-	fmt.Println(u)
+	err := p.ShowURL("Log in to your Jagex account", u)
+	if err != nil {
+		return nil, fmt.Errorf("showing url: %w", err)
+	}
 
-	var jagexCode string
-	err := huh.NewInput().
-		Title("Input the url returned").
-		Value(&jagexCode).
-		Run()
+	pasted, err := p.ReadRedirect("Input the url returned")
 	if err != nil {
 		return nil, fmt.Errorf("input: %w", err)
 	}
 
 	// jagex:code=8s9YzvGxdFVrZCV6o4-d5mvLzv0cU1vImzGvquOFBJU.x-5b1MEm3p5hjDxAJ4XMszE0uKg5nWYGMu_qrYcfZqc,state=12354124124,intent=social_auth
-	if !strings.HasPrefix(jagexCode, "jagex:") {
-		fmt.Println("Invalid code")
-		return nil, fmt.Errorf("invalid code, must start with 'jagex:'")
-	}
-
-	jagexCode = strings.TrimPrefix(jagexCode, "jagex:")
-	var code, state, intent string
-	list := strings.Split(jagexCode, ",")
-	for _, item := range list {
-		parts := strings.Split(item, "=")
-		switch parts[0] {
-		case "code":
-			code = parts[1]
-		case "state":
-			state = parts[1]
-		case "intent":
-			intent = parts[1]
-		}
+	result, err := redirect.Parse(pasted)
+	if err != nil {
+		return nil, fmt.Errorf("parsing redirect: %w", err)
+	}
+	if result.State != state {
+		return nil, fmt.Errorf("the redirect is from another login attempt, paste the one of the latest login")
+	}
+
+	switch LoginIntent(result.Intent) {
+	case IntentLogin, IntentSocialAuth:
+		// Both finish the login by exchanging the code.
+	default:
+		// A new step of the login page, exchanging the code could skip it.
+		return nil, fmt.Errorf("unknown login intent %q, the launcher does not know how to continue this login", result.Intent)
 	}
-	var _, _ = state, intent
-	//fmt.Println(state, intent)
 
-	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	token, err := cfg.Exchange(ctx, result.Code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		return nil, fmt.Errorf("exchange: %w", err)
 	}
@@ -0,0 +1,216 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/internal/metrics"
+	"github.com/Emyrk/osrs-launcher/internal/prompt"
+	"golang.org/x/oauth2"
+)
+
+// testTokenEndpoint answers the code exchange for code.
+func testTokenEndpoint(t *testing.T, code string) *oauth2.Config {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			t.Error(err)
+		}
+		if r.Form.Get("code") != code || r.Form.Get("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"id_token":      "id",
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := auth.JagexOAuthConfig()
+	cfg.Endpoint = oauth2.Endpoint{AuthURL: srv.URL + "/auth", TokenURL: srv.URL + "/token"}
+	return cfg
+}
+
+// statePrompter answers with scripted redirects whose STATE is replaced by the
+// state of the login url, like the login page would.
+type statePrompter struct {
+	*prompt.Script
+}
+
+func (p statePrompter) ReadRedirect(title string) (string, error) {
+	redirect, err := p.Script.ReadRedirect(title)
+	if err != nil || len(p.URLs) == 0 {
+		return redirect, err
+	}
+	u, err := url.Parse(p.URLs[len(p.URLs)-1])
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(redirect, "STATE", u.Query().Get("state")), nil
+}
+
+func TestAuthenticateJagexAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		redirect string
+		hint     string
+		err      bool
+	}{
+		{name: "jagex link", redirect: "jagex:code=abc,state=STATE,intent=social_auth"},
+		{name: "launcher redirect", redirect: "https://secure.runescape.com/m=weblogin/launcher-redirect?code=abc&state=STATE", hint: "me@example.com"},
+		{name: "wrong code", redirect: "jagex:code=other,state=STATE", err: true},
+		{name: "other attempt", redirect: "jagex:code=abc,state=0123456789abcdef", err: true},
+		{name: "no state", redirect: "jagex:code=abc", err: true},
+		{name: "unknown intent", redirect: "jagex:code=abc,state=STATE,intent=other", err: true},
+		{name: "login error", redirect: "jagex:error=access_denied", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testTokenEndpoint(t, "abc")
+			p := statePrompter{&prompt.Script{Redirects: []string{tt.redirect}}}
+			token, err := auth.AuthenticateJagexAccount(context.Background(), cfg, p, auth.LoginOptions{LoginHint: tt.hint})
+			if len(p.URLs) != 1 {
+				t.Fatalf("showed %d urls, want 1", len(p.URLs))
+			}
+			u, err2 := url.Parse(p.URLs[0])
+			if err2 != nil {
+				t.Fatal(err2)
+			}
+			q := u.Query()
+			if q.Get("client_id") != auth.LauncherClientID || q.Get("code_challenge_method") != "S256" || q.Get("login_hint") != tt.hint {
+				t.Errorf("unexpected login url %s", u)
+			}
+
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Token.RefreshToken != "refresh" || token.IDToken != "id" {
+				t.Errorf("got %+v", token)
+			}
+		})
+	}
+}
+
+func TestPasteConsent(t *testing.T) {
+	tests := []struct {
+		name      string
+		granted   bool
+		redirects []string
+		prompts   []string
+		err       bool
+	}{
+		{name: "first consent", redirects: []string{"http://localhost/#id_token=game&state=STATE"}, prompts: []string{"consent"}},
+		{name: "remembered", granted: true, redirects: []string{"http://localhost/#id_token=game&state=STATE"}, prompts: []string{"none"}},
+		{name: "not remembered", granted: true,
+			redirects: []string{"http://localhost/#error=consent_required&state=STATE", "http://localhost/#id_token=game&state=STATE"},
+			prompts:   []string{"none", "consent"}},
+		{name: "denied", granted: true, redirects: []string{"http://localhost/#error=access_denied"}, prompts: []string{"none"}, err: true},
+		{name: "page refused too",
+			redirects: []string{"http://localhost/#error=consent_required"}, prompts: []string{"consent"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &auth.JagexAccountAuth{IDToken: "id"}
+			if tt.granted {
+				a.ConsentGrantedAt = time.Now().Add(-time.Hour)
+			}
+			p := statePrompter{&prompt.Script{Redirects: tt.redirects}}
+			err := a.PasteConsent(auth.JagexOAuthConfig(), p)
+			if tt.err != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			var prompts []string
+			for _, raw := range p.URLs {
+				u, err := url.Parse(raw)
+				if err != nil {
+					t.Fatal(err)
+				}
+				prompts = append(prompts, u.Query().Get("prompt"))
+			}
+			if strings.Join(prompts, ",") != strings.Join(tt.prompts, ",") {
+				t.Errorf("got prompts %v, want %v", prompts, tt.prompts)
+			}
+			if !tt.err && a.GameIDToken != "game" {
+				t.Errorf("got game id token %q", a.GameIDToken)
+			}
+		})
+	}
+}
+
+func TestRefreshMetrics(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", requests),
+			"refresh_token": "refresh",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"id_token":      "id",
+		})
+	}))
+	defer srv.Close()
+	cfg := auth.JagexOAuthConfig()
+	cfg.Endpoint = oauth2.Endpoint{AuthURL: srv.URL + "/auth", TokenURL: srv.URL + "/token"}
+
+	before := refreshCount(t)
+
+	a := &auth.JagexAccountAuth{Token: oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)}}
+	for i := 0; i < 3; i++ {
+		err := a.Refresh(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 || a.Token.AccessToken != "access-1" {
+		t.Fatalf("got %d requests and access token %q, want one refresh", requests, a.Token.AccessToken)
+	}
+	if got := refreshCount(t) - before; got != 1 {
+		t.Errorf("counted %v refreshes, want 1", got)
+	}
+}
+
+// refreshCount sums the refresh requests that got a token.
+func refreshCount(t *testing.T) float64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, family := range families {
+		if family.GetName() != "osrs_launcher_token_refreshes_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "result" && label.GetValue() != "failed" {
+					sum += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return sum
+}
@@ -0,0 +1,21 @@
+package auth
+
+// Prompter is everything the login flow needs from a user. Keeping it behind
+// an interface lets the flow run from a terminal, a daemon, a web page or a
+// test script alike.
+type Prompter interface {
+	// ShowURL asks the user to visit url in a browser.
+	ShowURL(title, url string) error
+	// ReadRedirect reads the URL the browser was redirected to.
+	ReadRedirect(title string) (string, error)
+	// SelectAccount picks one of the saved accounts. With allowNew the user
+	// may instead log in to a new one, which is returned as "".
+	SelectAccount(accounts []string, allowNew bool) (string, error)
+	// SelectCharacter picks one of an account's characters.
+	SelectCharacter(chars []JagexCharacter) (JagexCharacter, error)
+	// Confirm asks a yes or no question.
+	Confirm(question string) (bool, error)
+	// SelectMany picks any number of options, all of them are selected at
+	// first. The picked options are returned in their original order.
+	SelectMany(title string, options []string) ([]string, error)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// WithProxy makes every request made with ctx go through the proxy, for
+// accounts that must not share an address with the others.
+func WithProxy(ctx context.Context, rawURL string) (context.Context, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("proxy url %q needs a scheme and host", rawURL)
+	}
+	cli := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(u)},
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, cli), nil
+}
+
+// httpClient is the client for requests that do not need the OAuth token:
+// the one set with WithProxy, or the default client.
+func httpClient(ctx context.Context) *http.Client {
+	if cli, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && cli != nil {
+		return cli
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreos/go-oidc"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// SetupOptions tune Setup.
+type SetupOptions struct {
+	// Headless has the user paste the consent redirect instead of listening
+	// for it on port 80.
+	Headless bool
+}
+
+// Setup takes a logged in account to a validated game session: it refreshes
+// and verifies the token, looks up the account's display name and asks the
+// user for consent through p when there is no session. The display name is
+// returned as soon as it is known, even with an error, so callers can save
+// the progress made.
+func (a *JagexAccountAuth) Setup(ctx context.Context, cfg *oauth2.Config, verifier *oidc.IDTokenVerifier, p Prompter, opts SetupOptions) (AccountDisplayName, error) {
+	log.Info().
+		Msg("Refreshing token if needed")
+	err := a.Refresh(ctx, cfg)
+	if err != nil {
+		return AccountDisplayName{}, fmt.Errorf("refresh token: %w", err)
+	}
+
+	idToken, err := a.VerifyAll(ctx, verifier)
+	log.Info().Err(err).Msg("Verifying token")
+	if err != nil {
+		return AccountDisplayName{}, fmt.Errorf("verifying token: %w", err)
+	}
+
+	userInfo, err := a.UserInfo(ctx, cfg)
+	if err != nil {
+		return AccountDisplayName{}, fmt.Errorf("getting user info: %w", err)
+	}
+
+	displayName, err := a.DisplayName(ctx, cfg, userInfo.Sub)
+	if err != nil {
+		return AccountDisplayName{}, fmt.Errorf("getting display name: %w", err)
+	}
+
+	needed := a.NeedsConsent(idToken)
+	if needed && opts.Headless {
+		err = a.PasteConsent(cfg, p)
+		if err != nil {
+			return displayName, fmt.Errorf("getting auth consent: %w", err)
+		}
+	} else if needed {
+		// We need to upgrade the consent
+		consent, done, err := a.AuthConsent(ctx, cfg)
+		if err != nil {
+			return displayName, fmt.Errorf("getting auth consent: %w", err)
+		}
+		err = p.ShowURL("Grant the launcher consent", consent)
+		if err != nil {
+			return displayName, fmt.Errorf("showing consent url: %w", err)
+		}
+
+		select {
+		case <-done:
+			log.Info().Msg("Consent complete")
+		case <-ctx.Done():
+			return displayName, ctx.Err()
+		}
+	}
+
+	if a.Session == "" {
+		if a.GameIDToken != a.IDToken {
+			_, err = a.VerifyGameIDToken(ctx, verifier)
+			if err != nil {
+				a.GameIDToken = ""
+				return displayName, fmt.Errorf("verifying game id token: %w", err)
+			}
+		}
+		err = a.Sessions(ctx, cfg)
+		if err != nil {
+			return displayName, fmt.Errorf("getting sessions: %w", err)
+		}
+	}
+
+	// Make sure the session is still valid
+	err = a.Accounts(ctx)
+	if err != nil {
+		return displayName, fmt.Errorf("getting sessions: %w", err)
+	}
+	return displayName, nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/coreos/go-oidc"
+)
+
+// LauncherClientID is the client the launcher logs in as.
+const LauncherClientID = "com_jagex_auth_desktop_launcher"
+
+// clockSkew is how far Jagex's clock may be ahead of ours.
+const clockSkew = time.Minute
+
+var (
+	// ErrIssuer is returned for tokens not issued by Jagex.
+	ErrIssuer = errors.New("id token has the wrong issuer")
+	// ErrUnknownAudience is returned for tokens issued to neither the
+	// launcher nor the game session client.
+	ErrUnknownAudience = errors.New("id token is for an unknown client")
+	// ErrAudience is returned for tokens of the other known client, like a
+	// game ID token saved as the launcher's.
+	ErrAudience = errors.New("id token is for another client")
+	// ErrAuthorizedParty is returned when the azp claim is missing from a
+	// token with several audiences, or names another client.
+	ErrAuthorizedParty = errors.New("id token has the wrong authorized party")
+	// ErrAuthTime is returned when auth_time is missing or after the token
+	// was issued.
+	ErrAuthTime = errors.New("id token has a bad auth_time")
+	// ErrNonce is returned when the token does not carry the nonce of the
+	// request it answers, it may be replayed from another login.
+	ErrNonce = errors.New("id token nonce does not match")
+	// ErrAccessTokenHash is returned when at_hash does not match the saved
+	// access token.
+	ErrAccessTokenHash = errors.New("id token does not belong to the access token")
+)
+
+// checkClaims checks the claims go-oidc leaves to the launcher: the token
+// must be Jagex's, for clientID, and answer the request with nonce when it is
+// set.
+func checkClaims(token *oidc.IDToken, clientID, nonce string) error {
+	if token.Issuer != JagexIssuer {
+		return fmt.Errorf("%w: %q", ErrIssuer, token.Issuer)
+	}
+
+	if !slices.Contains(token.Audience, LauncherClientID) && !slices.Contains(token.Audience, GameSessionClientID) {
+		return fmt.Errorf("%w: %q", ErrUnknownAudience, token.Audience)
+	}
+	if !slices.Contains(token.Audience, clientID) {
+		return fmt.Errorf("%w: %q, expected %q", ErrAudience, token.Audience, clientID)
+	}
+
+	var claims struct {
+		AZP      string `json:"azp"`
+		AuthTime int64  `json:"auth_time"`
+	}
+	err := token.Claims(&claims)
+	if err != nil {
+		return fmt.Errorf("decoding claims: %w", err)
+	}
+	if claims.AZP == "" && len(token.Audience) > 1 {
+		return fmt.Errorf("%w: missing with several audiences", ErrAuthorizedParty)
+	}
+	if claims.AZP != "" && claims.AZP != clientID {
+		return fmt.Errorf("%w: %q, expected %q", ErrAuthorizedParty, claims.AZP, clientID)
+	}
+
+	if claims.AuthTime == 0 {
+		return fmt.Errorf("%w: missing", ErrAuthTime)
+	}
+	authTime := time.Unix(claims.AuthTime, 0)
+	if authTime.After(token.IssuedAt.Add(clockSkew)) {
+		return fmt.Errorf("%w: %s is after the token was issued at %s", ErrAuthTime, authTime, token.IssuedAt)
+	}
+
+	if nonce != "" && token.Nonce != nonce {
+		return ErrNonce
+	}
+	return nil
+}
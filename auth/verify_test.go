@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"golang.org/x/oauth2"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// testIssuer signs ID tokens with a key served as a JWKS.
+type testIssuer struct {
+	signer   jose.Signer
+	verifier *oidc.IDTokenVerifier
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(srv.Close)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &Provider{
+		Discovery: Discovery{Issuer: JagexIssuer, SigningAlgorithms: []string{"RS256"}},
+		Keys:      &CachedKeySet{URL: srv.URL, Path: filepath.Join(t.TempDir(), jwksFile)},
+	}
+	return &testIssuer{signer: signer, verifier: JagexVerifier(provider)}
+}
+
+func (i *testIssuer) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := i.signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func launcherClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":       JagexIssuer,
+		"sub":       "user",
+		"aud":       LauncherClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+		"auth_time": now.Add(-time.Minute).Unix(),
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Now()
+	tests := []struct {
+		name     string
+		change   func(claims map[string]any)
+		clientID string
+		nonce    string
+		err      error
+	}{
+		{name: "launcher", change: func(map[string]any) {}},
+		{name: "game", clientID: GameSessionClientID, nonce: "n", change: func(c map[string]any) {
+			c["aud"] = GameSessionClientID
+			c["nonce"] = "n"
+		}},
+		{name: "several audiences with azp", change: func(c map[string]any) {
+			c["aud"] = []string{LauncherClientID, "other"}
+			c["azp"] = LauncherClientID
+		}},
+		{name: "wrong issuer", err: ErrIssuer, change: func(c map[string]any) {
+			c["iss"] = "https://account.jagex.com.evil/"
+		}},
+		{name: "unknown audience", err: ErrUnknownAudience, change: func(c map[string]any) {
+			c["aud"] = "other"
+		}},
+		{name: "other client", err: ErrAudience, change: func(c map[string]any) {
+			c["aud"] = GameSessionClientID
+		}},
+		{name: "several audiences without azp", err: ErrAuthorizedParty, change: func(c map[string]any) {
+			c["aud"] = []string{LauncherClientID, GameSessionClientID}
+		}},
+		{name: "azp of another client", err: ErrAuthorizedParty, change: func(c map[string]any) {
+			c["azp"] = GameSessionClientID
+		}},
+		{name: "no auth_time", err: ErrAuthTime, change: func(c map[string]any) {
+			delete(c, "auth_time")
+		}},
+		{name: "auth_time after iat", err: ErrAuthTime, change: func(c map[string]any) {
+			c["auth_time"] = now.Add(time.Hour).Unix()
+		}},
+		{name: "auth_time within skew", change: func(c map[string]any) {
+			c["auth_time"] = now.Add(clockSkew / 2).Unix()
+		}},
+		{name: "missing nonce", clientID: GameSessionClientID, nonce: "n", err: ErrNonce, change: func(c map[string]any) {
+			c["aud"] = GameSessionClientID
+		}},
+		{name: "wrong nonce", clientID: GameSessionClientID, nonce: "n", err: ErrNonce, change: func(c map[string]any) {
+			c["aud"] = GameSessionClientID
+			c["nonce"] = "other"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := launcherClaims()
+			tt.change(claims)
+			parsed, err := issuer.verifier.Verify(context.Background(), issuer.sign(t, claims))
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientID := tt.clientID
+			if clientID == "" {
+				clientID = LauncherClientID
+			}
+			err = checkClaims(parsed, clientID, tt.nonce)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	hash := sha256.Sum256([]byte("access"))
+	atHash := base64.RawURLEncoding.EncodeToString(hash[:len(hash)/2])
+
+	claims := launcherClaims()
+	claims["at_hash"] = atHash
+	a := JagexAccountAuth{Token: oauth2.Token{AccessToken: "access"}, IDToken: issuer.sign(t, claims)}
+	_, err := a.VerifyIDToken(context.Background(), issuer.verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Token.AccessToken = "another"
+	_, err = a.VerifyIDToken(context.Background(), issuer.verifier)
+	if !errors.Is(err, ErrAccessTokenHash) {
+		t.Errorf("got %v, want %v", err, ErrAccessTokenHash)
+	}
+
+	claims = launcherClaims()
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	a = JagexAccountAuth{IDToken: issuer.sign(t, claims)}
+	_, err = a.VerifyIDToken(context.Background(), issuer.verifier)
+	if err == nil {
+		t.Error("expired token verified")
+	}
+}
@@ -1,16 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"slices"
 	"strings"
 	"time"
 
 	"github.com/Emyrk/osrs-launcher/auth"
 	"github.com/Emyrk/osrs-launcher/config"
-	"github.com/charmbracelet/huh"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/Emyrk/osrs-launcher/internal/output"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
 
 	"github.com/coder/serpent"
 )
@@ -19,19 +22,56 @@ func (r *Root) Auth() *serpent.Command {
 	var (
 		noProxy           bool
 		outputDestination string
+		formats           []string
+		force             bool
+		relogin           bool
+		headless          bool
+		brk               brokerFlags
 	)
 
 	return &serpent.Command{
 		Use: "auth",
-		Options: serpent.OptionSet{
+		Options: append(serpent.OptionSet{
 			{
 				Name:          "Output Destination",
-				Description:   "Place to output the credentials.properties file to.",
+				Description:   "Place to output the credentials.properties file to. 'auto' writes to the RuneLite installs listed by the clients command.",
 				Flag:          "output-destination",
 				FlagShorthand: "O",
-				Default:       "$HOME/.runelite/credentials.properties",
+				Default:       autoDestination,
 				Value:         serpent.StringOf(&outputDestination),
 			},
+			{
+				Name: "Format",
+				Description: fmt.Sprintf("Credential formats to write, as 'FORMAT[:ARG][=DESTINATION]'. Repeat to write several. "+
+					"Formats are %s. 'template' takes a text/template file as its argument. "+
+					"The runelite format defaults to --output-destination, the others to stdout ('-').",
+					strings.Join(output.FormatNames(), ", ")),
+				Flag:          "format",
+				FlagShorthand: "f",
+				Default:       "runelite",
+				Value:         serpent.StringArrayOf(&formats),
+			},
+			{
+				Name:        "Force",
+				Description: "Overwrite RuneLite credentials even when a running client is using them.",
+				Flag:        "force",
+				Default:     "false",
+				Value:       serpent.BoolOf(&force),
+			},
+			{
+				Name:        "Login",
+				Description: "Log in to the selected account again even if its saved login works. The login page is pre-filled from the account's settings.",
+				Flag:        "login",
+				Default:     "false",
+				Value:       serpent.BoolOf(&relogin),
+			},
+			{
+				Name:        "Headless",
+				Description: "Paste both redirects instead of using a local browser and listening on port 80, for machines without a desktop.",
+				Flag:        "headless",
+				Default:     "false",
+				Value:       serpent.BoolOf(&headless),
+			},
 			{
 				Name:        "Disable Proxy",
 				Description: "Flag to force disable use of the proxychains configuration.",
@@ -39,41 +79,75 @@ func (r *Root) Auth() *serpent.Command {
 				Default:     "false",
 				Value:       serpent.BoolOf(&noProxy),
 			},
-		},
+		}, brk.options()...),
 		Middleware: serpent.Chain(r.LoggerMW(), UseProxy),
 		Handler: func(i *serpent.Invocation) error {
 			ctx := i.Context()
-			err := auth.TestPort80()
+			if headless {
+				r.NoBrowser = true
+			}
+			prompter := r.Prompter(i)
+			targets := make([]output.Target, 0, len(formats))
+			for _, spec := range formats {
+				target, err := output.ParseTarget(spec, func(name string) string {
+					if name == "runelite" {
+						return outputDestination
+					}
+					return output.Stdout
+				})
+				if err != nil {
+					return fmt.Errorf("format %q: %w", spec, err)
+				}
+				if target.Destination != autoDestination {
+					targets = append(targets, target)
+					continue
+				}
+
+				paths, err := detectedCredentialPaths(prompter)
+				if err != nil {
+					return fmt.Errorf("choosing RuneLite install: %w", err)
+				}
+				for _, path := range paths {
+					target.Destination = path
+					targets = append(targets, target)
+				}
+			}
+
+			err := checkRunningClients(targets, force)
 			if err != nil {
-				if strings.Contains(err.Error(), "permission denied") {
-					return fmt.Errorf("port 80 is blocked, you must grant permission for this program to listen on this port. Run 'sudo setcap CAP_NET_BIND_SERVICE=+eip `which %s`'", os.Args[0])
+				return err
+			}
+			if brk.enabled() {
+				return authViaBroker(i, prompter, brk, targets)
+			}
+
+			if !headless {
+				err = auth.TestPort80()
+				if err != nil {
+					if strings.Contains(err.Error(), "permission denied") {
+						return fmt.Errorf("port 80 is blocked, you must grant permission for this program to listen on this port. Run 'sudo setcap CAP_NET_BIND_SERVICE=+eip `which %s`' or use --headless", os.Args[0])
+					}
+					return fmt.Errorf("testing port 80: %w", err)
 				}
-				return fmt.Errorf("testing port 80: %w", err)
 			}
 
-			provider, err := auth.JagexProvider()
+			root := config.DefaultDir().Init()
+			provider, err := auth.JagexProvider(ctx, root.CacheDir())
 			if err != nil {
 				return fmt.Errorf("getting provider: %w", err)
 			}
 			verifier := auth.JagexVerifier(provider)
 
-			root := config.DefaultDir().Init()
 			all, err := root.Accounts()
-			options := make([]huh.Option[string], 0, len(all))
-			options = append(options, huh.NewOption("New Account", "new"))
+			if err != nil {
+				return fmt.Errorf("listing accounts: %w", err)
+			}
+			names := make([]string, 0, len(all))
 			for _, account := range all {
-				options = append(options, huh.Option[string]{
-					Key:   account.Name(),
-					Value: account.Name(),
-				})
+				names = append(names, account.Name())
 			}
 
-			var sel string
-			err = huh.NewSelect[string]().
-				Title("Select Jagex account to authenticate").
-				Options(options...).
-				Value(&sel).
-				Run()
+			sel, err := prompter.SelectAccount(names, true)
 			if err != nil {
 				return fmt.Errorf("selecting: %w", err)
 			}
@@ -81,115 +155,77 @@ func (r *Root) Auth() *serpent.Command {
 			var acct *auth.JagexAccountAuth
 			cfg := auth.JagexOAuthConfig()
 
-			if sel == "new" {
-				newToken, err := auth.AuthenticateJagexAccount(ctx, cfg)
+			if sel == "" {
+				newToken, err := auth.AuthenticateJagexAccount(ctx, cfg, prompter, auth.LoginOptions{})
 				if err != nil {
 					return fmt.Errorf("getting oauth token: %w", err)
 				}
 				acct = newToken
 			} else {
 				account := root.Account(sel)
+				unlock, err := account.Lock(ctx)
+				if err != nil {
+					return fmt.Errorf("locking account: %w", err)
+				}
+				defer unlock()
+				// Refreshing, logging in again and the setup all go through
+				// the account's proxy.
+				ctx, err = account.Context(ctx)
+				if err != nil {
+					return fmt.Errorf("account %q: %w", account.Name(), err)
+				}
+
 				existingToken, err := account.Token()
 				if err != nil {
 					return fmt.Errorf("getting token from save: %w", err)
 				}
 				acct = &existingToken
-			}
-
-			log.Info().
-				Msg("Refreshing token if needed")
-			err = acct.Refresh(ctx, cfg)
-			if err != nil {
-				return fmt.Errorf("refresh token: %w", err)
-			}
-
-			idToken, err := acct.VerifyAll(ctx, verifier)
-			log.Info().Err(err).Msg("Verifying token")
-			if err != nil {
-				return fmt.Errorf("verifying token: %w", err)
-			}
-			var _ = idToken
-
-			userInfo, err := acct.UserInfo(ctx, cfg)
-			if err != nil {
-				return fmt.Errorf("getting user info: %w", err)
-			}
 
-			displayName, err := acct.DisplayName(ctx, cfg, userInfo.Sub)
-			if err != nil {
-				return fmt.Errorf("getting display name: %w", err)
-			}
-
-			//if slices.Contains(idToken.Audience, "com_jagex_auth_desktop_launcher") {
-			if acct.GameIDToken == "" && acct.Session == "" {
-				// We need to upgrade the consent
-				consent, done, err := acct.AuthConsent(ctx, cfg)
-				if err != nil {
-					return fmt.Errorf("getting auth consent: %w", err)
+				if !relogin {
+					err = acct.Refresh(ctx, cfg)
+					if keeper.Classify(err) == keeper.StateNeedsLogin {
+						log.Warn().Err(err).Msg("The saved login was rejected, log in again")
+						relogin = true
+					}
 				}
-				fmt.Printf("Consent URL, please visit: %s\n", consent)
-
-				select {
-				case <-done:
-					log.Info().Msg("Consent complete")
-				case <-ctx.Done():
-					log.Info().Msg("Context cancelled")
+				if relogin {
+					acct, err = loginAgain(ctx, cfg, prompter, account)
+					if err != nil {
+						return fmt.Errorf("getting oauth token: %w", err)
+					}
 				}
 			}
 
-			log.Info().
-				Str("display_name", displayName.DisplayName).
-				Msg("Saving token to disk")
-			defer func() {
-				err = root.Account(displayName.DisplayName).SaveToken(acct)
-				if err != nil {
+			displayName, err := acct.Setup(ctx, cfg, verifier, prompter, auth.SetupOptions{Headless: headless})
+			if displayName.DisplayName != "" {
+				log.Info().
+					Str("display_name", displayName.DisplayName).
+					Msg("Saving token to disk")
+				saveErr := root.Account(displayName.DisplayName).SaveToken(acct)
+				if saveErr != nil {
 					log.Error().
-						Err(err).
+						Err(saveErr).
 						Msg("saving token to disk")
 				}
-			}()
-
-			if acct.Session == "" {
-				err = acct.Sessions(ctx, cfg)
-				if err != nil {
-					return fmt.Errorf("getting sessions: %w", err)
-				}
+				rememberUserID(root.Account(displayName.DisplayName), displayName)
 			}
-
-			// Make sure the session is still valid
-			err = acct.Accounts(ctx)
 			if err != nil {
-				return fmt.Errorf("getting sessions: %w", err)
+				return err
 			}
 
-			opts := make([]huh.Option[string], 0, len(acct.Characters))
-			for _, char := range acct.Characters {
-				opts = append(opts, huh.NewOption(char.DisplayName, char.AccountID))
-			}
-
-			var characterID string
-			err = huh.NewSelect[string]().
-				Title("Select character").
-				Options(opts...).
-				Value(&characterID).
-				Run()
+			character, err := prompter.SelectCharacter(acct.Characters)
 			if err != nil {
 				return fmt.Errorf("selecting character: %w", err)
 			}
 
-			character := acct.Characters[slices.IndexFunc(acct.Characters, func(c auth.JagexCharacter) bool {
-				return c.AccountID == characterID
-			})]
-
-			err = os.WriteFile(os.ExpandEnv(outputDestination), []byte(fmt.Sprintf(`
-			JX_CHARACTER_ID=%s
-			JX_SESSION_ID=%s
-			JX_REFRESH_TOKEN=
-			JX_DISPLAY_NAME=%s
-			JX_ACCESS_TOKEN=
-			`, character.AccountID, acct.Session, character.DisplayName)), 0644)
+			err = writeTargets(i.Stdout, targets, output.Credentials{
+				Account:     displayName.DisplayName,
+				CharacterID: character.AccountID,
+				SessionID:   acct.Session,
+				DisplayName: character.DisplayName,
+			})
 			if err != nil {
-				return fmt.Errorf("writing credentials file: %w", err)
+				return err
 			}
 
 			log.Info().Msg("Runelite is set! Closing this window.")
@@ -198,3 +234,99 @@ func (r *Root) Auth() *serpent.Command {
 		},
 	}
 }
+
+// loginAgain logs in to a saved account with the login page pre-filled from
+// its settings.
+func loginAgain(ctx context.Context, cfg *oauth2.Config, prompter auth.Prompter, account config.Account) (*auth.JagexAccountAuth, error) {
+	var opts auth.LoginOptions
+	settings, err := account.Settings()
+	if err == nil {
+		opts.LoginHint, err = settings.LoginHint(ctx)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("account", account.Name()).Msg("No login hint, the login page starts empty")
+	}
+	return auth.AuthenticateJagexAccount(ctx, cfg, withOTP(prompter, os.Stderr, account), opts)
+}
+
+// rememberUserID records the account's user ID, it is the login hint of
+// accounts without an email.
+func rememberUserID(account config.Account, displayName auth.AccountDisplayName) {
+	settings, err := account.Settings()
+	if err != nil || displayName.UserID == "" || settings.UserID == displayName.UserID {
+		return
+	}
+	settings.UserID = displayName.UserID
+	err = account.SaveSettings(settings)
+	if err != nil {
+		log.Error().Err(err).Str("account", account.Name()).Msg("saving settings")
+	}
+}
+
+func writeTargets(stdout io.Writer, targets []output.Target, creds output.Credentials) error {
+	for _, target := range targets {
+		err := target.Write(stdout, creds)
+		if err != nil {
+			return fmt.Errorf("writing %s credentials: %w", target.Name, err)
+		}
+		if target.Destination != output.Stdout {
+			log.Info().
+				Str("format", target.Name).
+				Str("destination", target.Destination).
+				Msg("Credentials written")
+		}
+	}
+	return nil
+}
+
+// authViaBroker gets the session from a broker instead of logging in, the
+// account's tokens never reach this machine.
+func authViaBroker(i *serpent.Invocation, prompter auth.Prompter, brk brokerFlags, targets []output.Target) error {
+	ctx := i.Context()
+	bc, err := brk.client()
+	if err != nil {
+		return err
+	}
+
+	accounts, err := bc.Accounts(ctx)
+	if err != nil {
+		return fmt.Errorf("listing broker accounts: %w", err)
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("the broker does not allow this client any accounts")
+	}
+	names := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		if account.State != "" && account.State != keeper.StateOK {
+			log.Warn().
+				Str("account", account.Name).
+				Str("state", string(account.State)).
+				Msg("Broker account is not usable until it is fixed on the broker")
+		}
+		names = append(names, account.Name)
+	}
+
+	sel, err := prompter.SelectAccount(names, false)
+	if err != nil {
+		return fmt.Errorf("selecting: %w", err)
+	}
+
+	token, err := bc.Token(ctx, sel)
+	if err != nil {
+		return err
+	}
+	character, err := auth.FindCharacter(token.Characters, "")
+	if err != nil {
+		character, err = prompter.SelectCharacter(token.Characters)
+		if err != nil {
+			return fmt.Errorf("selecting character: %w", err)
+		}
+	}
+
+	return writeTargets(i.Stdout, targets, output.Credentials{
+		Account:     sel,
+		CharacterID: character.AccountID,
+		SessionID:   token.Session,
+		DisplayName: character.DisplayName,
+	})
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/api"
+	"github.com/Emyrk/osrs-launcher/internal/broker"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/coder/serpent"
+)
+
+var GroupBroker = &serpent.Group{
+	Name:        "Broker",
+	YAML:        "broker",
+	Description: "Get game sessions from a team broker instead of locally stored accounts.",
+}
+
+// brokerFlags are the options of commands that can use a broker.
+type brokerFlags struct {
+	url  string
+	cert string
+	key  string
+	ca   string
+}
+
+func (b *brokerFlags) options() serpent.OptionSet {
+	return serpent.OptionSet{
+		{
+			Name:        "Broker URL",
+			Description: "HTTPS URL of a broker started with 'broker serve'. No tokens are stored locally when it is set.",
+			Flag:        "broker",
+			Env:         "OSRS_LAUNCHER_BROKER",
+			Value:       serpent.StringOf(&b.url),
+			Group:       GroupBroker,
+		},
+		{
+			Name:        "Broker Certificate",
+			Description: "Client certificate presented to the broker.",
+			Flag:        "broker-cert",
+			Env:         "OSRS_LAUNCHER_BROKER_CERT",
+			Value:       serpent.StringOf(&b.cert),
+			Group:       GroupBroker,
+		},
+		{
+			Name:        "Broker Key",
+			Description: "Private key of the client certificate.",
+			Flag:        "broker-key",
+			Env:         "OSRS_LAUNCHER_BROKER_KEY",
+			Value:       serpent.StringOf(&b.key),
+			Group:       GroupBroker,
+		},
+		{
+			Name:        "Broker CA",
+			Description: "CA that signed the broker's certificate. Defaults to the system roots.",
+			Flag:        "broker-ca",
+			Env:         "OSRS_LAUNCHER_BROKER_CA",
+			Value:       serpent.StringOf(&b.ca),
+			Group:       GroupBroker,
+		},
+	}
+}
+
+func (b *brokerFlags) enabled() bool {
+	return b.url != ""
+}
+
+func (b *brokerFlags) client() (*broker.Client, error) {
+	if b.cert == "" || b.key == "" {
+		return nil, fmt.Errorf("--broker-cert and --broker-key are required with --broker")
+	}
+	tlsConfig, err := broker.ClientTLSConfig(b.cert, b.key, b.ca)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewClient(b.url, tlsConfig)
+}
+
+func (r *Root) Broker() *serpent.Command {
+	cmd := &serpent.Command{
+		Use:   "broker",
+		Short: "Share this machine's accounts with a team over mutual TLS.",
+	}
+	cmd.AddSubcommands(r.brokerServe())
+	return cmd
+}
+
+func (r *Root) brokerServe() *serpent.Command {
+	var (
+		noProxy  bool
+		listen   string
+		certFile string
+		keyFile  string
+		caFile   string
+		aclFile  string
+		auditLog string
+		interval time.Duration
+	)
+
+	return &serpent.Command{
+		Use:   "serve",
+		Short: "Hand out game sessions of the saved accounts to clients with a certificate signed by --client-ca.",
+		Long: "Clients are identified by the common name of their certificate and may only use the " +
+			"accounts the ACL grants them. The ACL is a JSON file like " +
+			`{"clients": {"alice-laptop": {"accounts": ["main", "alt*"]}}}. ` +
+			"Every request is written to the audit log. Tokens are kept fresh like 'serve' does. " +
+			"Clients get the account's own game session, Jagex sessions cannot be scoped or " +
+			"expire sooner, and removing a client from the ACL does not revoke a session it already got.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Listen",
+				Description: "Address to serve on.",
+				Flag:        "listen",
+				Default:     ":8443",
+				Value:       serpent.StringOf(&listen),
+			},
+			{
+				Name:        "TLS Certificate",
+				Description: "Server certificate.",
+				Flag:        "tls-cert",
+				Required:    true,
+				Value:       serpent.StringOf(&certFile),
+			},
+			{
+				Name:        "TLS Key",
+				Description: "Server private key.",
+				Flag:        "tls-key",
+				Required:    true,
+				Value:       serpent.StringOf(&keyFile),
+			},
+			{
+				Name:        "Client CA",
+				Description: "CA that signs client certificates.",
+				Flag:        "client-ca",
+				Required:    true,
+				Value:       serpent.StringOf(&caFile),
+			},
+			{
+				Name:        "ACL",
+				Description: "JSON file listing the accounts each client may use.",
+				Flag:        "acl",
+				Required:    true,
+				Value:       serpent.StringOf(&aclFile),
+			},
+			{
+				Name:        "Audit Log",
+				Description: "File the audit log is appended to.",
+				Flag:        "audit-log",
+				Default:     "broker-audit.log",
+				Value:       serpent.StringOf(&auditLog),
+			},
+			{
+				Name:        "Interval",
+				Description: "How often to refresh and validate every account.",
+				Flag:        "interval",
+				Default:     "15m",
+				Value:       serpent.DurationOf(&interval),
+			},
+			{
+				Name:        "Disable Proxy",
+				Description: "Flag to force disable use of the proxychains configuration.",
+				Flag:        "no-proxy",
+				Default:     "false",
+				Value:       serpent.BoolOf(&noProxy),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), UseProxy),
+		Handler: func(i *serpent.Invocation) error {
+			ctx, stop := signal.NotifyContext(i.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if interval <= 0 {
+				return fmt.Errorf("interval must be positive")
+			}
+
+			acl, err := broker.LoadACL(aclFile)
+			if err != nil {
+				return fmt.Errorf("loading ACL: %w", err)
+			}
+			tlsConfig, err := broker.ServerTLSConfig(certFile, keyFile, caFile)
+			if err != nil {
+				return err
+			}
+			audit, err := broker.OpenAudit(auditLog)
+			if err != nil {
+				return fmt.Errorf("opening audit log: %w", err)
+			}
+			defer audit.Close()
+
+			root := config.DefaultDir().Init()
+			k := keeper.New(root, interval)
+			srv := &broker.Server{
+				// Clients launch on their own machines, never on the broker.
+				API:   api.New(root, k, nil),
+				ACL:   acl,
+				Audit: audit,
+			}
+			log.Info().
+				Str("listen", listen).
+				Int("clients", len(acl.Clients)).
+				Msg("Broker started")
+
+			// A listener that fails, like on an address in use, stops the
+			// keeper as well.
+			eg, ctx := errgroup.WithContext(ctx)
+			eg.Go(func() error { return k.Run(ctx) })
+			eg.Go(func() error { return srv.Serve(ctx, listen, tlsConfig) })
+			return eg.Wait()
+		},
+	}
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/output"
+	"github.com/Emyrk/osrs-launcher/internal/runelite"
+	"github.com/rs/zerolog/log"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) Clients() *serpent.Command {
+	return &serpent.Command{
+		Use:        "clients",
+		Short:      "List the RuneLite installs found on this machine, where they read credentials from and which are running.",
+		Options:    serpent.OptionSet{},
+		Middleware: r.LoggerMW(),
+		Handler: func(i *serpent.Invocation) error {
+			installs := runelite.Detect()
+			if len(installs) == 0 {
+				_, _ = fmt.Fprintf(i.Stdout, "No RuneLite installs found, credentials default to %s\n",
+					runelite.Install{ConfigDir: runelite.DefaultConfigDir()}.CredentialsPath())
+			} else {
+				tw := tabwriter.NewWriter(i.Stdout, 0, 4, 2, ' ', 0)
+				_, _ = fmt.Fprintln(tw, "VARIANT\tPATH\tCREDENTIALS\tEXISTS")
+				for _, install := range installs {
+					path := install.Path
+					if path == "" {
+						path = "-"
+					}
+					_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%t\n",
+						install.Variant, path, install.CredentialsPath(),
+						config.File(install.CredentialsPath()).Exists())
+				}
+				_ = tw.Flush()
+			}
+
+			procs, err := runelite.Running()
+			if err != nil {
+				return fmt.Errorf("scanning processes: %w", err)
+			}
+			if len(procs) == 0 {
+				return nil
+			}
+			_, _ = fmt.Fprintln(i.Stdout)
+			tw := tabwriter.NewWriter(i.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "PID\tCHARACTER\tACCOUNT\tCONFIG")
+			for _, proc := range procs {
+				_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n",
+					proc.PID, orDash(proc.Character), orDash(proc.Account), proc.ConfigDir)
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+// autoDestination is the --output-destination that picks the credentials
+// files of detected RuneLite installs.
+const autoDestination = "auto"
+
+// detectedCredentialPaths returns the credentials files of the detected
+// RuneLite installs. When installs disagree on where they read credentials
+// from, the user picks which ones to write.
+func detectedCredentialPaths(p auth.Prompter) ([]string, error) {
+	installs := runelite.Detect()
+	dirs := runelite.ConfigDirs(installs)
+	switch len(dirs) {
+	case 0:
+		return []string{runelite.Install{ConfigDir: runelite.DefaultConfigDir()}.CredentialsPath()}, nil
+	case 1:
+		return []string{runelite.Install{ConfigDir: dirs[0]}.CredentialsPath()}, nil
+	}
+
+	options := make([]string, 0, len(dirs))
+	paths := make(map[string]string, len(dirs))
+	for _, dir := range dirs {
+		var variants []string
+		for _, install := range installs {
+			if install.ConfigDir == dir && !slices.Contains(variants, string(install.Variant)) {
+				variants = append(variants, string(install.Variant))
+			}
+		}
+		path := runelite.Install{ConfigDir: dir}.CredentialsPath()
+		option := fmt.Sprintf("%s (%s)", path, strings.Join(variants, ", "))
+		options = append(options, option)
+		paths[option] = path
+	}
+
+	picked, err := p.SelectMany("Select the RuneLite installs to write credentials for", options)
+	if err != nil {
+		return nil, fmt.Errorf("selecting: %w", err)
+	}
+	if len(picked) == 0 {
+		return nil, fmt.Errorf("no RuneLite install selected")
+	}
+	selected := make([]string, 0, len(picked))
+	for _, option := range picked {
+		selected = append(selected, paths[option])
+	}
+	return selected, nil
+}
+
+// checkRunningClients refuses to swap RuneLite credentials underneath a
+// running client. With force it only warns.
+func checkRunningClients(targets []output.Target, force bool) error {
+	procs, err := runelite.Running()
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot check for running RuneLite clients")
+		return nil
+	}
+
+	for _, target := range targets {
+		if target.Name != "runelite" || target.Destination == output.Stdout {
+			continue
+		}
+		using := runelite.UsingConfigDir(procs, filepath.Dir(target.Destination))
+		if len(using) == 0 {
+			continue
+		}
+
+		pids := make([]string, 0, len(using))
+		for _, proc := range using {
+			desc := strconv.Itoa(proc.PID)
+			if proc.Character != "" {
+				desc += " (" + proc.Character + ")"
+			}
+			pids = append(pids, desc)
+		}
+		if !force {
+			return fmt.Errorf("RuneLite is running with %s (pid %s), close it or pass --force to overwrite its credentials",
+				target.Destination, strings.Join(pids, ", "))
+		}
+		log.Warn().
+			Str("destination", target.Destination).
+			Strs("pids", pids).
+			Msg("Overwriting credentials of a running RuneLite client")
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// useCharacter writes the character's credentials to every detected RuneLite
+// install without asking, for the web UI and dashboard.
+func useCharacter(account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error {
+	dirs := runelite.ConfigDirs(runelite.Detect())
+	if len(dirs) == 0 {
+		dirs = []string{runelite.DefaultConfigDir()}
+	}
+	targets := make([]output.Target, 0, len(dirs))
+	for _, dir := range dirs {
+		targets = append(targets, output.Target{
+			Name:        "runelite",
+			Format:      output.RuneLite{},
+			Destination: runelite.Install{ConfigDir: dir}.CredentialsPath(),
+		})
+	}
+
+	err := checkRunningClients(targets, false)
+	if err != nil {
+		return err
+	}
+	return writeTargets(io.Discard, targets, output.Credentials{
+		Account:     account.Name(),
+		CharacterID: character.AccountID,
+		SessionID:   token.Session,
+		DisplayName: character.DisplayName,
+	})
+}
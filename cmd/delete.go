@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/Emyrk/osrs-launcher/config"
-	"github.com/charmbracelet/huh"
 	"github.com/rs/zerolog/log"
 
 	"github.com/coder/serpent"
@@ -19,24 +18,26 @@ func (r *Root) Delete() *serpent.Command {
 		Handler: func(i *serpent.Invocation) error {
 			root := config.DefaultDir().Init()
 			all, err := root.Accounts()
-			options := make([]huh.Option[string], 0, len(all))
+			if err != nil {
+				return fmt.Errorf("listing accounts: %w", err)
+			}
+			names := make([]string, 0, len(all))
 			for _, account := range all {
-				options = append(options, huh.Option[string]{
-					Key:   account.Name(),
-					Value: account.Name(),
-				})
+				names = append(names, account.Name())
 			}
-			options = append(options)
 
-			var sel string
-			err = huh.NewSelect[string]().
-				Title("Select Jagex account to authenticate").
-				Options(options...).
-				Value(&sel).
-				Run()
+			prompter := r.Prompter(i)
+			sel, err := prompter.SelectAccount(names, false)
 			if err != nil {
 				return fmt.Errorf("selecting: %w", err)
 			}
+			ok, err := prompter.Confirm(fmt.Sprintf("Delete the saved tokens of %s?", sel))
+			if err != nil {
+				return fmt.Errorf("confirming: %w", err)
+			}
+			if !ok {
+				return nil
+			}
 
 			err = root.Account(sel).Delete()
 			if err != nil {
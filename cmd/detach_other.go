@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cmd
+
+import "syscall"
+
+// detachedAttr keeps the defaults, the background launcher is not tied to a
+// terminal session here.
+func detachedAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package cmd
+
+import "syscall"
+
+// detachedAttr starts the background launcher in its own session, so it
+// survives the terminal that started it closing.
+func detachedAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/rs/zerolog/log"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) Group() *serpent.Command {
+	cmd := &serpent.Command{
+		Use:   "group",
+		Short: "Manage named groups of characters that are launched together.",
+	}
+	cmd.AddSubcommands(
+		r.groupList(),
+		r.groupAdd(),
+		r.groupRemove(),
+	)
+	return cmd
+}
+
+func (r *Root) groupList() *serpent.Command {
+	return &serpent.Command{
+		Use:        "list",
+		Aliases:    []string{"ls"},
+		Short:      "List saved groups and their members.",
+		Middleware: r.LoggerMW(),
+		Handler: func(i *serpent.Invocation) error {
+			groups, err := config.DefaultDir().Init().Groups()
+			if err != nil {
+				return fmt.Errorf("loading groups: %w", err)
+			}
+			for _, name := range config.GroupNames(groups) {
+				members := make([]string, 0, len(groups[name].Members))
+				for _, m := range groups[name].Members {
+					members = append(members, m.String())
+				}
+				_, _ = fmt.Fprintf(i.Stdout, "%s: %s\n", name, strings.Join(members, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func (r *Root) groupAdd() *serpent.Command {
+	return &serpent.Command{
+		Use:        "add <group> <account[/character]>...",
+		Short:      "Add characters to a group, creating it if needed.",
+		Middleware: serpent.Chain(r.LoggerMW(), serpent.RequireRangeArgs(2, -1)),
+		Handler: func(i *serpent.Invocation) error {
+			root := config.DefaultDir().Init()
+			groups, err := root.Groups()
+			if err != nil {
+				return fmt.Errorf("loading groups: %w", err)
+			}
+
+			name := i.Args[0]
+			group := groups[name]
+			for _, arg := range i.Args[1:] {
+				member, err := config.ParseGroupMember(arg)
+				if err != nil {
+					return err
+				}
+				if !root.Account(member.Account).Exists() {
+					// Broker users have no local accounts.
+					log.Warn().Str("account", member.Account).Msg("Account is not saved locally, launching needs auth or a broker")
+				}
+				if slices.Contains(group.Members, member) {
+					continue
+				}
+				group.Members = append(group.Members, member)
+			}
+			groups[name] = group
+
+			err = root.SaveGroups(groups)
+			if err != nil {
+				return fmt.Errorf("saving groups: %w", err)
+			}
+			log.Info().Str("group", name).Int("members", len(group.Members)).Msg("Group saved")
+			return nil
+		},
+	}
+}
+
+func (r *Root) groupRemove() *serpent.Command {
+	return &serpent.Command{
+		Use:        "remove <group> [account[/character]]...",
+		Aliases:    []string{"rm"},
+		Short:      "Remove characters from a group, or the whole group if none are given.",
+		Middleware: serpent.Chain(r.LoggerMW(), serpent.RequireRangeArgs(1, -1)),
+		Handler: func(i *serpent.Invocation) error {
+			root := config.DefaultDir().Init()
+			groups, err := root.Groups()
+			if err != nil {
+				return fmt.Errorf("loading groups: %w", err)
+			}
+
+			name := i.Args[0]
+			group, ok := groups[name]
+			if !ok {
+				return fmt.Errorf("no group named %q", name)
+			}
+			if len(i.Args) == 1 {
+				delete(groups, name)
+			} else {
+				for _, arg := range i.Args[1:] {
+					member, err := config.ParseGroupMember(arg)
+					if err != nil {
+						return err
+					}
+					group.Members = slices.DeleteFunc(group.Members, func(m config.GroupMember) bool {
+						return m == member
+					})
+				}
+				groups[name] = group
+			}
+
+			err = root.SaveGroups(groups)
+			if err != nil {
+				return fmt.Errorf("saving groups: %w", err)
+			}
+			log.Info().Str("group", name).Msg("Group updated")
+			return nil
+		},
+	}
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/manifest"
+	"github.com/Emyrk/osrs-launcher/internal/totp"
+	"github.com/coreos/go-oidc"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) ImportManifest() *serpent.Command {
+	var (
+		noProxy  bool
+		restart  bool
+		headless bool
+	)
+
+	return &serpent.Command{
+		Use:   "import-manifest <accounts.yaml>",
+		Short: "Log in to every account of a manifest, one after the other.",
+		Long: "Each account of the manifest has a label and optionally an email, a proxy URL, a group " +
+			"to join, a default character and an authenticator seed. Progress is saved next to the manifest after every " +
+			"account, running the import again skips the accounts that were imported.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Restart",
+				Description: "Ignore the saved progress and import every account again.",
+				Flag:        "restart",
+				Default:     "false",
+				Value:       serpent.BoolOf(&restart),
+			},
+			{
+				Name:        "Headless",
+				Description: "Paste both redirects instead of using a local browser and listening on port 80, for machines without a desktop.",
+				Flag:        "headless",
+				Default:     "false",
+				Value:       serpent.BoolOf(&headless),
+			},
+			{
+				Name:        "Disable Proxy",
+				Description: "Flag to force disable use of the proxychains configuration.",
+				Flag:        "no-proxy",
+				Default:     "false",
+				Value:       serpent.BoolOf(&noProxy),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), serpent.RequireNArgs(1), UseProxy),
+		Handler: func(i *serpent.Invocation) error {
+			ctx, stop := signal.NotifyContext(i.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			path := i.Args[0]
+			m, err := manifest.Load(path)
+			if err != nil {
+				return err
+			}
+			cp, err := manifest.LoadCheckpoint(manifest.CheckpointPath(path))
+			if err != nil {
+				return err
+			}
+
+			if headless {
+				r.NoBrowser = true
+			} else {
+				err = auth.TestPort80()
+				if err != nil {
+					return fmt.Errorf("testing port 80: %w", err)
+				}
+			}
+			root := config.DefaultDir().Init()
+			provider, err := auth.JagexProvider(ctx, root.CacheDir())
+			if err != nil {
+				return fmt.Errorf("getting provider: %w", err)
+			}
+			verifier := auth.JagexVerifier(provider)
+			prompter := r.Prompter(i)
+
+			for n, entry := range m.Accounts {
+				if cp.Results[entry.Label].Done() && !restart {
+					log.Info().Str("label", entry.Label).Msg("Already imported, skipping")
+					continue
+				}
+				_, _ = fmt.Fprintf(i.Stderr, "\n[%d/%d] %s %s\n", n+1, len(m.Accounts), entry.Label, entry.Email)
+
+				result := importEntry(ctx, root, entry, auth.JagexOAuthConfig(), verifier, prompter, auth.SetupOptions{Headless: headless})
+				if ctx.Err() != nil {
+					// Interrupted, the entry is retried on the next run.
+					break
+				}
+				if result.Error != "" {
+					log.Error().Str("label", entry.Label).Str("error", result.Error).Msg("Import failed")
+				}
+				err = cp.Record(entry.Label, result)
+				if err != nil {
+					return fmt.Errorf("saving checkpoint: %w", err)
+				}
+			}
+
+			tw := tabwriter.NewWriter(i.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "LABEL\tACCOUNT\tCHARACTER\tGROUP\tRESULT")
+			failed := 0
+			for _, entry := range m.Accounts {
+				res, ok := cp.Results[entry.Label]
+				status := "ok"
+				switch {
+				case !ok:
+					status = "pending"
+					failed++
+				case res.Error != "":
+					status = res.Error
+					failed++
+				}
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.Label, orDash(res.Account), orDash(res.Character), orDash(entry.Group), status)
+			}
+			err = tw.Flush()
+			if err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("import interrupted, run it again to resume")
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d accounts were not imported, run the import again to retry them", failed, len(m.Accounts))
+			}
+			return nil
+		},
+	}
+}
+
+// importEntry logs in to the entry's account and saves it with its settings.
+func importEntry(ctx context.Context, root config.Root, entry manifest.Entry, cfg *oauth2.Config, verifier *oidc.IDTokenVerifier, prompter auth.Prompter, opts auth.SetupOptions) manifest.Result {
+	var result manifest.Result
+	fail := func(err error) manifest.Result {
+		result.Error = err.Error()
+		return result
+	}
+
+	if entry.Proxy != "" {
+		var err error
+		ctx, err = auth.WithProxy(ctx, entry.Proxy)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	loginPrompter := prompter
+	if entry.TOTP != "" {
+		// The account has no name until the login, the code is shown from
+		// the manifest's seed.
+		key, _ := totp.Parse(entry.TOTP)
+		loginPrompter = otpPrompter{Prompter: prompter, key: key, out: os.Stderr}
+	}
+	token, err := auth.AuthenticateJagexAccount(ctx, cfg, loginPrompter, auth.LoginOptions{LoginHint: entry.Email})
+	if err != nil {
+		return fail(fmt.Errorf("getting oauth token: %w", err))
+	}
+	displayName, err := token.Setup(ctx, cfg, verifier, prompter, opts)
+	if displayName.DisplayName == "" {
+		if err == nil {
+			err = errors.New("no display name")
+		}
+		return fail(err)
+	}
+	result.Account = displayName.DisplayName
+	account := root.Account(displayName.DisplayName)
+	saveErr := account.SaveToken(token)
+	if saveErr != nil {
+		return fail(fmt.Errorf("saving token: %w", saveErr))
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	settings, err := account.Settings()
+	if err != nil {
+		return fail(err)
+	}
+	settings.Label = entry.Label
+	settings.Email = entry.Email
+	settings.Proxy = entry.Proxy
+	settings.UserID = displayName.UserID
+	if entry.Character != "" {
+		character, err := auth.FindCharacter(token.Characters, entry.Character)
+		if err != nil {
+			return fail(err)
+		}
+		settings.DefaultCharacter = character.DisplayName
+		result.Character = character.DisplayName
+	} else if len(token.Characters) == 1 {
+		result.Character = token.Characters[0].DisplayName
+	}
+	err = account.SaveSettings(settings)
+	if err != nil {
+		return fail(fmt.Errorf("saving settings: %w", err))
+	}
+	if entry.TOTP != "" {
+		err = account.SaveTOTP(entry.TOTP)
+		if err != nil {
+			return fail(fmt.Errorf("saving authenticator seed: %w", err))
+		}
+	}
+
+	if entry.Group != "" {
+		err = joinGroup(root, entry.Group, config.GroupMember{Account: account.Name(), Character: settings.DefaultCharacter})
+		if err != nil {
+			return fail(fmt.Errorf("joining group %q: %w", entry.Group, err))
+		}
+	}
+	log.Info().
+		Str("label", entry.Label).
+		Str("account", account.Name()).
+		Str("characters", characterNames(token.Characters)).
+		Msg("Account imported")
+	return result
+}
+
+func joinGroup(root config.Root, name string, member config.GroupMember) error {
+	groups, err := root.Groups()
+	if err != nil {
+		return err
+	}
+	group := groups[name]
+	if slices.Contains(group.Members, member) {
+		return nil
+	}
+	group.Members = append(group.Members, member)
+	groups[name] = group
+	return root.SaveGroups(groups)
+}
+
+func characterNames(chars []auth.JagexCharacter) string {
+	names := make([]string, 0, len(chars))
+	for _, c := range chars {
+		names = append(names, c.DisplayName)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,475 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/Emyrk/osrs-launcher/internal/output"
+	"github.com/Emyrk/osrs-launcher/internal/runelite"
+	"github.com/Emyrk/osrs-launcher/internal/supervisor"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) Launch() *serpent.Command {
+	var (
+		noProxy     bool
+		client      string
+		group       string
+		concurrency int64
+		stagger     time.Duration
+		detach      bool
+		sup         supervision
+		brk         brokerFlags
+	)
+
+	return &serpent.Command{
+		Use:   "launch [<account> [character]]",
+		Short: "Start RuneLite for a character in its own isolated RuneLite home.",
+		Long: "Every character gets a separate home directory under the launcher's config directory, " +
+			"seeded from ~/.runelite the first time, so several clients can run at once without " +
+			"sharing credentials, settings or profiles. The launcher stays running to supervise " +
+			"the clients, see the ps and stop commands.",
+		Options: append(serpent.OptionSet{
+			{
+				Name:        "Client",
+				Description: "RuneLite to start: a path to a binary, AppImage or jar, or 'flatpak'. Defaults to the first install found by the clients command.",
+				Flag:        "client",
+				Value:       serpent.StringOf(&client),
+			},
+			{
+				Name:          "Group",
+				Description:   "Launch every character of a group, see the group command.",
+				Flag:          "group",
+				FlagShorthand: "g",
+				Value:         serpent.StringOf(&group),
+			},
+			{
+				Name:        "Concurrency",
+				Description: "How many accounts of a group to refresh at the same time.",
+				Flag:        "concurrency",
+				Default:     "3",
+				Value:       serpent.Int64Of(&concurrency),
+			},
+			{
+				Name:        "Stagger",
+				Description: "Delay between starting the clients of a group.",
+				Flag:        "stagger",
+				Default:     "0s",
+				Value:       serpent.DurationOf(&stagger),
+			},
+			{
+				Name:        "Restart",
+				Description: "Restart clients that exit abnormally, with an increasing delay between attempts.",
+				Flag:        "restart",
+				Default:     "false",
+				Value:       serpent.BoolOf(&sup.restart),
+			},
+			{
+				Name:        "Max Restarts",
+				Description: "Give up after this many restarts in a row, 0 is unlimited.",
+				Flag:        "max-restarts",
+				Default:     "5",
+				Value:       serpent.Int64Of(&sup.maxRestarts),
+			},
+			{
+				Name:        "Detach",
+				Description: "Supervise the clients from a background process instead of this terminal. The character must be given when the account has several.",
+				Flag:        "detach",
+				Default:     "false",
+				Value:       serpent.BoolOf(&detach),
+			},
+			{
+				Name:        "Disable Proxy",
+				Description: "Flag to force disable use of the proxychains configuration.",
+				Flag:        "no-proxy",
+				Default:     "false",
+				Value:       serpent.BoolOf(&noProxy),
+			},
+		}, brk.options()...),
+		Middleware: serpent.Chain(r.LoggerMW(), UseProxy, serpent.RequireRangeArgs(0, 2)),
+		Handler: func(i *serpent.Invocation) error {
+			if brk.enabled() {
+				bc, err := brk.client()
+				if err != nil {
+					return err
+				}
+				sup.sessions = func(ctx context.Context, account config.Account) (auth.JagexAccountAuth, error) {
+					return bc.Token(ctx, account.Name())
+				}
+			}
+			if group == "" && len(i.Args) == 0 {
+				return fmt.Errorf("an account or --group is required")
+			}
+			if group != "" && len(i.Args) > 0 {
+				return fmt.Errorf("--group cannot be combined with an account")
+			}
+
+			root := config.DefaultDir().Init()
+			if detach {
+				return detachLaunch(root)
+			}
+
+			ctx, stop := signal.NotifyContext(i.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			install, err := resolveInstall(client)
+			if err != nil {
+				return err
+			}
+
+			if group != "" {
+				g, err := root.Group(group)
+				if err != nil {
+					return err
+				}
+				return launchGroup(ctx, i.Stdout, root, g, install, int(concurrency), stagger, sup)
+			}
+
+			account := root.Account(i.Args[0])
+			token, err := sup.session(ctx, account)
+			if err != nil {
+				return err
+			}
+
+			var query string
+			if len(i.Args) > 1 {
+				query = i.Args[1]
+			} else if settings, err := account.Settings(); err == nil {
+				query = settings.DefaultCharacter
+			}
+			character, err := auth.FindCharacter(token.Characters, query)
+			if err != nil && query == "" {
+				character, err = r.Prompter(i).SelectCharacter(token.Characters)
+			}
+			if err != nil {
+				return err
+			}
+
+			client := sup.supervisor(ctx, root, account, token, character, install)
+			err = client.Start(ctx)
+			if err != nil {
+				return fmt.Errorf("starting %s: %w", install.Variant, err)
+			}
+			log.Info().
+				Str("character", character.DisplayName).
+				Int("pid", client.PID()).
+				Str("log", client.LogFile()).
+				Msg("RuneLite started")
+			return client.Wait()
+		},
+	}
+}
+
+// sessionFor loads the account and makes sure it has a valid game session.
+// The token is saved unless that failed for a transient reason, since
+// refreshing or invalidating a session changes it. The account stays locked
+// in between so other launcher processes do not refresh it at the same time.
+func sessionFor(ctx context.Context, account config.Account) (auth.JagexAccountAuth, error) {
+	unlock, err := account.Lock(ctx)
+	if err != nil {
+		return auth.JagexAccountAuth{}, fmt.Errorf("locking account %q: %w", account.Name(), err)
+	}
+	defer unlock()
+
+	token, err := account.Token()
+	if err != nil {
+		return token, fmt.Errorf("loading account %q: %w", account.Name(), err)
+	}
+
+	ctx, err = account.Context(ctx)
+	if err != nil {
+		return token, fmt.Errorf("account %q: %w", account.Name(), err)
+	}
+	err = token.EnsureSession(ctx, auth.JagexOAuthConfig())
+	if saveErr := keeper.SaveToken(account, token, err); saveErr != nil {
+		log.Error().Err(saveErr).Str("account", account.Name()).Msg("saving token to disk")
+	}
+	if err != nil {
+		return token, fmt.Errorf("account %q: %w", account.Name(), err)
+	}
+	return token, nil
+}
+
+// prepareLaunch seeds the character's home, writes its credentials there and
+// returns the command that starts the client.
+func prepareLaunch(root config.Root, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter, install runelite.Install) (*exec.Cmd, error) {
+	home := root.Home(character.AccountID)
+	if !home.Exists() {
+		log.Info().
+			Str("character", character.DisplayName).
+			Str("home", string(home)).
+			Msg("Creating RuneLite home from ~/.runelite")
+	}
+	err := runelite.SeedHome(runelite.DefaultConfigDir(), home.ConfigDir())
+	if err != nil {
+		return nil, fmt.Errorf("seeding RuneLite home: %w", err)
+	}
+
+	creds := output.Credentials{
+		Account:     account.Name(),
+		CharacterID: character.AccountID,
+		SessionID:   token.Session,
+		DisplayName: character.DisplayName,
+	}
+	target := output.Target{
+		Name:        "runelite",
+		Format:      output.RuneLite{},
+		Destination: runelite.Install{ConfigDir: home.ConfigDir()}.CredentialsPath(),
+	}
+	err = target.Write(nil, creds)
+	if err != nil {
+		return nil, fmt.Errorf("writing credentials: %w", err)
+	}
+
+	// The supervisor stops the client itself, it sends SIGTERM to the whole
+	// process group and only kills it if it does not exit in time.
+	env := append(creds.Environ(),
+		runelite.EnvCharacter+"="+character.DisplayName,
+		runelite.EnvAccount+"="+account.Name(),
+	)
+	return install.Command(context.Background(), string(home), env)
+}
+
+// resolveInstall turns the --client flag into an install to start.
+func resolveInstall(client string) (runelite.Install, error) {
+	switch {
+	case client == "":
+		install, ok := runelite.Launchable(runelite.Detect())
+		if !ok {
+			return runelite.Install{}, fmt.Errorf("no RuneLite install found, use --client to point at one")
+		}
+		return install, nil
+	case client == "flatpak":
+		return runelite.Install{Variant: runelite.VariantFlatpak, Path: runelite.FlatpakID}, nil
+	case strings.HasSuffix(client, ".jar"):
+		return runelite.Install{Variant: runelite.VariantJar, Path: client}, nil
+	case strings.HasSuffix(strings.ToLower(client), ".appimage"):
+		return runelite.Install{Variant: runelite.VariantAppImage, Path: client}, nil
+	}
+
+	path, err := exec.LookPath(client)
+	if err != nil {
+		return runelite.Install{}, fmt.Errorf("client %q: %w", client, err)
+	}
+	path, _ = filepath.Abs(path)
+	return runelite.Install{Variant: runelite.VariantNative, Path: path}, nil
+}
+
+type launchResult struct {
+	member    config.GroupMember
+	character string
+	pid       int
+	err       error
+}
+
+// launchGroup refreshes the sessions of every account in the group, at most
+// concurrency at a time, then starts one client per member. Members fail
+// independently, a broken account does not stop the rest of the group.
+func launchGroup(ctx context.Context, out io.Writer, root config.Root, group config.Group, install runelite.Install, concurrency int, stagger time.Duration, sup supervision) error {
+	if len(group.Members) == 0 {
+		return fmt.Errorf("group has no members")
+	}
+
+	// Several members can share an account, each account is refreshed once.
+	var (
+		mu       sync.Mutex
+		sessions = make(map[string]auth.JagexAccountAuth)
+		failures = make(map[string]error)
+	)
+	var eg errgroup.Group
+	if concurrency > 0 {
+		eg.SetLimit(concurrency)
+	}
+	seen := make(map[string]bool)
+	for _, member := range group.Members {
+		if seen[member.Account] {
+			continue
+		}
+		seen[member.Account] = true
+		account := root.Account(member.Account)
+		eg.Go(func() error {
+			token, err := sup.session(ctx, account)
+			mu.Lock()
+			defer mu.Unlock()
+			sessions[account.Name()] = token
+			if err != nil {
+				failures[account.Name()] = err
+			}
+			log.Info().Err(err).Str("account", account.Name()).Msg("Session checked")
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	results := make([]launchResult, 0, len(group.Members))
+	var clients []*supervisor.Supervisor
+	for _, member := range group.Members {
+		res := launchResult{member: member, err: failures[member.Account]}
+		if res.err == nil && len(clients) > 0 && stagger > 0 {
+			select {
+			case <-time.After(stagger):
+			case <-ctx.Done():
+			}
+		}
+		if res.err == nil && ctx.Err() != nil {
+			// Interrupted, the members left are reported as not started and
+			// the clients already running are still waited for below.
+			res.err = fmt.Errorf("not started: %w", ctx.Err())
+		}
+		if res.err == nil {
+			var client *supervisor.Supervisor
+			res.character, client, res.err = startMember(ctx, root, member, sessions[member.Account], install, sup)
+			if res.err == nil {
+				res.pid = client.PID()
+				clients = append(clients, client)
+			}
+		}
+		results = append(results, res)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "MEMBER\tCHARACTER\tRESULT")
+	failed := 0
+	for _, res := range results {
+		status := fmt.Sprintf("started (pid %d)", res.pid)
+		if res.err != nil {
+			failed++
+			status = "failed: " + res.err.Error()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", res.member, res.character, status)
+	}
+	_ = tw.Flush()
+
+	var launchErr error
+	if failed > 0 {
+		launchErr = fmt.Errorf("%d of %d characters failed to launch", failed, len(results))
+	}
+
+	// Keep supervising the clients that did start.
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client *supervisor.Supervisor) {
+			defer wg.Done()
+			err := client.Wait()
+			if err != nil {
+				log.Error().Err(err).Str("character", client.Name).Msg("Client supervision ended")
+			}
+		}(client)
+	}
+	wg.Wait()
+	return launchErr
+}
+
+func startMember(ctx context.Context, root config.Root, member config.GroupMember, token auth.JagexAccountAuth, install runelite.Install, sup supervision) (string, *supervisor.Supervisor, error) {
+	character, err := auth.FindCharacter(token.Characters, member.Character)
+	if err != nil {
+		return member.Character, nil, err
+	}
+	client := sup.supervisor(ctx, root, root.Account(member.Account), token, character, install)
+	err = client.Start(ctx)
+	if err != nil {
+		return character.DisplayName, nil, fmt.Errorf("starting %s: %w", install.Variant, err)
+	}
+	return character.DisplayName, client, nil
+}
+
+// supervision is how launched clients are supervised.
+type supervision struct {
+	restart     bool
+	maxRestarts int64
+	// sessions replaces the locally stored accounts, for example with a
+	// broker.
+	sessions sessionSource
+}
+
+// sessionSource returns an account with a valid game session.
+type sessionSource func(ctx context.Context, account config.Account) (auth.JagexAccountAuth, error)
+
+func (s supervision) session(ctx context.Context, account config.Account) (auth.JagexAccountAuth, error) {
+	if s.sessions != nil {
+		return s.sessions(ctx, account)
+	}
+	return sessionFor(ctx, account)
+}
+
+// supervisor returns a supervisor for the character. The first start uses
+// token as is, restarts refresh the session first since the old one may be
+// what made the client exit.
+func (s supervision) supervisor(ctx context.Context, root config.Root, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter, install runelite.Install) *supervisor.Supervisor {
+	first := true
+	return &supervisor.Supervisor{
+		ID:      character.AccountID,
+		Name:    character.DisplayName,
+		Account: account.Name(),
+		Command: func() (*exec.Cmd, error) {
+			if !first {
+				var err error
+				token, err = s.session(ctx, account)
+				if err != nil {
+					return nil, err
+				}
+			}
+			first = false
+			return prepareLaunch(root, account, token, character, install)
+		},
+		StateDir:    root.StateDir(),
+		LogDir:      root.LogDir(),
+		Restart:     s.restart,
+		MaxRestarts: int(s.maxRestarts),
+	}
+}
+
+// detachLaunch starts this same command again in a new session, without
+// --detach, and returns once it is running. The background launcher writes
+// its own rotating log, nothing of it is tied to this process.
+func detachLaunch(root config.Root) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding executable: %w", err)
+	}
+	logPath := filepath.Join(root.LogDir(), "launcher.log")
+	err = os.MkdirAll(root.LogDir(), 0o750)
+	if err != nil {
+		return fmt.Errorf("creating log dir: %w", err)
+	}
+	// Only output that bypasses the logger, like a panic, ends up here.
+	stderr, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening launcher log: %w", err)
+	}
+	defer stderr.Close()
+
+	// The last occurrence of a flag wins.
+	cmd := exec.Command(exe, append(os.Args[1:], "--detach=false", "--log-human=false", "--log-file", logPath)...)
+	// A nil stdin and stdout are /dev/null, and an *os.File is handed to the
+	// child as is. Anything else would be a pipe that closes when we exit.
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = detachedAttr()
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("starting background launcher: %w", err)
+	}
+	log.Info().
+		Int("pid", cmd.Process.Pid).
+		Str("log", logPath).
+		Msg("Launcher running in the background")
+	return cmd.Process.Release()
+}
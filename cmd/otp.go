@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/prompt"
+	"github.com/Emyrk/osrs-launcher/internal/totp"
+	"github.com/rs/zerolog/log"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) OTP() *serpent.Command {
+	var (
+		watch  bool
+		set    bool
+		remove bool
+	)
+
+	return &serpent.Command{
+		Use:   "otp <account>",
+		Short: "Print the current authenticator code of an account.",
+		Long: "Seeds are saved with --set, which reads a base32 secret or an otpauth:// URI from " +
+			"stdin so it stays out of the shell history. They are stored next to the account's " +
+			"token, encrypted with a key derived from the passphrase in " + config.PassphraseEnv + ", " +
+			"which has to be set to save or use a seed. During auth the code is shown with a " +
+			"countdown while the redirect is pasted.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Watch",
+				Description: "Keep printing codes with a countdown until interrupted.",
+				Flag:        "watch",
+				Default:     "false",
+				Value:       serpent.BoolOf(&watch),
+			},
+			{
+				Name:        "Set",
+				Description: "Save the account's seed, read from stdin.",
+				Flag:        "set",
+				Default:     "false",
+				Value:       serpent.BoolOf(&set),
+			},
+			{
+				Name:        "Remove",
+				Description: "Delete the account's seed.",
+				Flag:        "remove",
+				Default:     "false",
+				Value:       serpent.BoolOf(&remove),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), serpent.RequireNArgs(1)),
+		Handler: func(i *serpent.Invocation) error {
+			root := config.DefaultDir().Init()
+			account := root.Account(i.Args[0])
+			if !account.Exists() {
+				return fmt.Errorf("no saved account %q", account.Name())
+			}
+
+			switch {
+			case set:
+				_, _ = fmt.Fprint(i.Stderr, "Authenticator seed: ")
+				seed, err := bufio.NewReader(i.Stdin).ReadString('\n')
+				if err != nil && seed == "" {
+					return fmt.Errorf("reading seed: %w", err)
+				}
+				err = account.SaveTOTP(strings.TrimSpace(seed))
+				if err != nil {
+					return fmt.Errorf("saving seed: %w", err)
+				}
+				log.Info().Str("account", account.Name()).Msg("Authenticator seed saved")
+				return nil
+			case remove:
+				err := account.DeleteTOTP()
+				if err != nil {
+					return fmt.Errorf("deleting seed: %w", err)
+				}
+				log.Info().Str("account", account.Name()).Msg("Authenticator seed deleted")
+				return nil
+			}
+
+			key, ok, err := account.TOTP()
+			if err != nil {
+				return fmt.Errorf("loading seed: %w", err)
+			}
+			if !ok {
+				return fmt.Errorf("account %q has no authenticator seed, save one with --set", account.Name())
+			}
+			if !watch {
+				code, err := key.Code(time.Now())
+				if err != nil {
+					return fmt.Errorf("generating code: %w", err)
+				}
+				_, _ = fmt.Fprintln(i.Stdout, code)
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(i.Context(), os.Interrupt)
+			defer stop()
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				now := time.Now()
+				code, err := key.Code(now)
+				if err != nil {
+					return fmt.Errorf("generating code: %w", err)
+				}
+				_, _ = fmt.Fprintf(i.Stdout, "\r%s  %2ds ", code, int(key.Remaining(now).Seconds()))
+				select {
+				case <-ctx.Done():
+					_, _ = fmt.Fprintln(i.Stdout)
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+}
+
+// otpPrompter shows the account's authenticator code, with a countdown where
+// the prompter can redraw it, while the login redirect is pasted.
+type otpPrompter struct {
+	auth.Prompter
+	key totp.Key
+	out io.Writer
+}
+
+// withOTP wraps p to show codes when the account has a seed.
+func withOTP(p auth.Prompter, out io.Writer, account config.Account) auth.Prompter {
+	key, ok, err := account.TOTP()
+	if err != nil {
+		log.Warn().Err(err).Str("account", account.Name()).Msg("Cannot load the authenticator seed")
+	}
+	if !ok {
+		return p
+	}
+	return otpPrompter{Prompter: p, key: key, out: out}
+}
+
+// ReadRedirect shows the code while waiting, the login page asks for it
+// before it redirects.
+func (o otpPrompter) ReadRedirect(title string) (string, error) {
+	return prompt.ReadRedirectStatus(o.Prompter, o.out, title, o.status)
+}
+
+func (o otpPrompter) status(now time.Time) string {
+	code, err := o.key.Code(now)
+	if err != nil {
+		return fmt.Sprintf("Authenticator code: %v", err)
+	}
+	remaining := o.key.Remaining(now)
+	line := fmt.Sprintf("Authenticator code: %s (valid for %ds)", code, int(remaining.Seconds()))
+	if remaining < 10*time.Second {
+		// Too little time to type it, show the next one as well.
+		next, _ := o.key.Code(now.Add(remaining))
+		line += fmt.Sprintf(", next code: %s", next)
+	}
+	return line
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/supervisor"
+	"github.com/rs/zerolog/log"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) PS() *serpent.Command {
+	var all bool
+
+	return &serpent.Command{
+		Use:   "ps",
+		Short: "List the clients supervised by the launcher.",
+		Options: serpent.OptionSet{
+			{
+				Name:          "All",
+				Description:   "Include clients that are no longer running.",
+				Flag:          "all",
+				FlagShorthand: "a",
+				Default:       "false",
+				Value:         serpent.BoolOf(&all),
+			},
+		},
+		Middleware: r.LoggerMW(),
+		Handler: func(i *serpent.Invocation) error {
+			states, err := supervisor.List(config.DefaultDir().Init().StateDir())
+			if err != nil {
+				return fmt.Errorf("listing clients: %w", err)
+			}
+
+			tw := tabwriter.NewWriter(i.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "CHARACTER\tACCOUNT\tSTATUS\tPID\tSTARTED\tRESTARTS\tLOG")
+			for _, st := range states {
+				status := string(st.Status)
+				running := st.Status == supervisor.StatusRunning || st.Status == supervisor.StatusRestarting
+				if running && !st.Alive() {
+					// The supervisor was killed without getting to record it.
+					status, running = "lost", false
+				}
+				if !running && !all {
+					continue
+				}
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\t%s\n",
+					st.Name, st.Account, status, st.PID,
+					st.StartedAt.Format(time.DateTime), st.Restarts, st.LogFile)
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func (r *Root) Stop() *serpent.Command {
+	return &serpent.Command{
+		Use:        "stop <character>",
+		Short:      "Stop a supervised client without restarting it.",
+		Middleware: serpent.Chain(r.LoggerMW(), serpent.RequireNArgs(1)),
+		Handler: func(i *serpent.Invocation) error {
+			st, err := supervisor.Stop(config.DefaultDir().Init().StateDir(), i.Args[0])
+			if errors.Is(err, supervisor.ErrNotRunning) {
+				return fmt.Errorf("%s is not running", st.Name)
+			}
+			if err != nil {
+				return err
+			}
+			log.Info().
+				Str("character", st.Name).
+				Int("pid", st.PID).
+				Msg("Client stopping")
+			return nil
+		},
+	}
+}
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/internal/prompt"
+	"github.com/Emyrk/osrs-launcher/internal/supervisor"
 	"github.com/Emyrk/osrs-launcher/internal/version"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
@@ -21,8 +25,11 @@ var (
 )
 
 type Root struct {
-	LogHuman bool
-	LogLevel string
+	LogHuman  bool
+	LogLevel  string
+	LogFile   string
+	Prompt    string
+	NoBrowser bool
 }
 
 func New() *Root {
@@ -54,6 +61,34 @@ func (r *Root) RootCmd() *serpent.Command {
 				Value:       serpent.EnumOf(&r.LogLevel, "trace", "debug", "info", "warn", "error", "fatal", "panic"),
 				Group:       GroupLogs,
 			},
+			{
+				Name:        "log-file",
+				Description: "Write logs to this file instead of stderr, rotating it once it grows past 10MiB.",
+				Flag:        "log-file",
+				Env:         "OSRS_LAUNCHER_LOG_FILE",
+				YAML:        "log_file",
+				Value:       serpent.StringOf(&r.LogFile),
+				Group:       GroupLogs,
+				Hidden:      true,
+			},
+			{
+				Name:        "prompt",
+				Description: "How to ask questions. 'tty' uses interactive forms, 'lines' reads plain answers from stdin, 'auto' picks tty when stdin is a terminal.",
+				Flag:        "prompt",
+				Env:         "OSRS_LAUNCHER_PROMPT",
+				YAML:        "prompt",
+				Default:     "auto",
+				Value:       serpent.EnumOf(&r.Prompt, prompt.Modes()...),
+			},
+			{
+				Name:        "no-browser",
+				Description: "Do not open login urls in a browser, show them as a QR code instead.",
+				Flag:        "no-browser",
+				Env:         "OSRS_LAUNCHER_NO_BROWSER",
+				YAML:        "no_browser",
+				Default:     "false",
+				Value:       serpent.BoolOf(&r.NoBrowser),
+			},
 		},
 	}
 
@@ -61,12 +96,35 @@ func (r *Root) RootCmd() *serpent.Command {
 		versionCmd(),
 		r.Auth(),
 		r.Delete(),
+		r.Clients(),
+		r.Launch(),
+		r.Group(),
+		r.PS(),
+		r.Stop(),
+		r.Serve(),
+		r.Web(),
+		r.TUI(),
+		r.ImportManifest(),
+		r.Settings(),
+		r.OTP(),
+		r.Token(),
+		r.Broker(),
 		r.ProxyTest(),
 	)
 
 	return cmd
 }
 
+// Prompter asks the user questions the way --prompt selects, and opens urls
+// unless --no-browser is set.
+func (r *Root) Prompter(inv *serpent.Invocation) auth.Prompter {
+	return prompt.Browser{
+		Prompter:  prompt.New(r.Prompt, inv.Stdin, inv.Stderr),
+		Out:       inv.Stderr,
+		NoBrowser: r.NoBrowser,
+	}
+}
+
 func (r *Root) LoggerMW() func(next serpent.HandlerFunc) serpent.HandlerFunc {
 	return func(next serpent.HandlerFunc) serpent.HandlerFunc {
 		return func(i *serpent.Invocation) error {
@@ -78,10 +136,13 @@ func (r *Root) LoggerMW() func(next serpent.HandlerFunc) serpent.HandlerFunc {
 }
 
 func (r *Root) Logger(inv *serpent.Invocation) zerolog.Logger {
-	out := inv.Stderr
+	var out io.Writer = inv.Stderr
+	if r.LogFile != "" {
+		out = &supervisor.RotatingFile{Path: r.LogFile, MaxSize: 10 << 20, MaxFiles: 3}
+	}
 	if r.LogHuman {
 		// human format it!
-		out = zerolog.ConsoleWriter{Out: inv.Stderr}
+		out = zerolog.ConsoleWriter{Out: out}
 	}
 
 	var logger zerolog.Logger
@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/api"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/Emyrk/osrs-launcher/internal/metrics"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) Serve() *serpent.Command {
+	var (
+		noProxy        bool
+		interval       time.Duration
+		socket         string
+		noAPI          bool
+		metricsAddress string
+		client         string
+		sup            supervision
+	)
+
+	cmd := &serpent.Command{
+		Use:   "serve",
+		Short: "Run a daemon that keeps every account's tokens and game sessions fresh.",
+		Long: "Accounts that need a human to log in or grant consent again are logged and " +
+			"reported in status.json in the config directory. A JSON API for scripts is served " +
+			"on a Unix socket only the current user can use.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Interval",
+				Description: "How often to refresh and validate every account.",
+				Flag:        "interval",
+				Env:         "OSRS_LAUNCHER_INTERVAL",
+				Default:     "15m",
+				Value:       serpent.DurationOf(&interval),
+			},
+			{
+				Name:        "Socket",
+				Description: "Unix socket to serve the API on. Defaults to api.sock in the config directory.",
+				Flag:        "socket",
+				Env:         "OSRS_LAUNCHER_SOCKET",
+				Value:       serpent.StringOf(&socket),
+			},
+			{
+				Name:        "Disable API",
+				Description: "Do not serve the API.",
+				Flag:        "no-api",
+				Default:     "false",
+				Value:       serpent.BoolOf(&noAPI),
+			},
+			{
+				Name:        "Metrics Address",
+				Description: "Address to serve Prometheus metrics on at /metrics, like 127.0.0.1:9150. Disabled when empty.",
+				Flag:        "metrics-address",
+				Env:         "OSRS_LAUNCHER_METRICS_ADDRESS",
+				Value:       serpent.StringOf(&metricsAddress),
+			},
+			{
+				Name:        "Client",
+				Description: "RuneLite to start for API launches, see launch --client.",
+				Flag:        "client",
+				Value:       serpent.StringOf(&client),
+			},
+			{
+				Name:        "Restart",
+				Description: "Restart clients launched through the API when they exit abnormally.",
+				Flag:        "restart",
+				Default:     "false",
+				Value:       serpent.BoolOf(&sup.restart),
+			},
+			{
+				Name:        "Max Restarts",
+				Description: "Give up after this many restarts in a row, 0 is unlimited.",
+				Flag:        "max-restarts",
+				Default:     "5",
+				Value:       serpent.Int64Of(&sup.maxRestarts),
+			},
+			{
+				Name:        "Disable Proxy",
+				Description: "Flag to force disable use of the proxychains configuration.",
+				Flag:        "no-proxy",
+				Default:     "false",
+				Value:       serpent.BoolOf(&noProxy),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), UseProxy),
+		Handler: func(i *serpent.Invocation) error {
+			ctx, stop := signal.NotifyContext(i.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if interval <= 0 {
+				return fmt.Errorf("interval must be positive")
+			}
+
+			root := config.DefaultDir().Init()
+			k := keeper.New(root, interval)
+			log.Info().
+				Dur("interval", interval).
+				Str("status", string(root.StatusFile())).
+				Msg("Session keeper started")
+
+			// Whatever fails first stops the rest.
+			eg, ctx := errgroup.WithContext(ctx)
+			eg.Go(func() error { return k.Run(ctx) })
+
+			if metricsAddress != "" {
+				l, err := net.Listen("tcp", metricsAddress)
+				if err != nil {
+					return fmt.Errorf("listening on %s: %w", metricsAddress, err)
+				}
+				metrics.Registry.MustRegister(k)
+				log.Info().Str("address", l.Addr().String()).Msg("Serving metrics")
+				eg.Go(func() error { return serveMetrics(ctx, l) })
+			}
+
+			if !noAPI {
+				if socket == "" {
+					socket = root.SocketPath()
+				}
+				l, err := api.ListenUnix(socket)
+				if err != nil {
+					return fmt.Errorf("listening on %s: %w", socket, err)
+				}
+				launch, stopClients := apiLauncher(ctx, root, client, sup)
+				defer stopClients()
+				srv := api.New(root, k, launch)
+				log.Info().Str("socket", socket).Msg("Serving API")
+				eg.Go(func() error { return srv.Serve(ctx, l) })
+			}
+			return eg.Wait()
+		},
+	}
+	cmd.AddSubcommands(r.systemdUnit())
+	return cmd
+}
+
+// apiLauncher launches clients for the API. They are supervised by the
+// daemon, so they live as long as it does rather than as long as the request.
+// The returned stop function stops the clients and waits for their
+// supervisors, which remove the clients' state and pidfiles, so it must run
+// before the daemon exits.
+func apiLauncher(ctx context.Context, root config.Root, client string, sup supervision) (api.Launcher, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		mu      sync.Mutex
+		stopped bool
+		wg      sync.WaitGroup
+	)
+	stop := func() {
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		cancel()
+		wg.Wait()
+	}
+
+	return func(_ context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) (api.LaunchResponse, error) {
+		mu.Lock()
+		if stopped {
+			mu.Unlock()
+			return api.LaunchResponse{}, fmt.Errorf("shutting down")
+		}
+		wg.Add(1)
+		mu.Unlock()
+
+		install, err := resolveInstall(client)
+		if err != nil {
+			wg.Done()
+			return api.LaunchResponse{}, err
+		}
+		proc := sup.supervisor(ctx, root, account, token, character, install)
+		err = proc.Start(ctx)
+		if err != nil {
+			wg.Done()
+			return api.LaunchResponse{}, fmt.Errorf("starting %s: %w", install.Variant, err)
+		}
+		go func() {
+			defer wg.Done()
+			err := proc.Wait()
+			if err != nil {
+				log.Error().Err(err).Str("character", character.DisplayName).Msg("Client supervision ended")
+			}
+		}()
+
+		log.Info().
+			Str("character", character.DisplayName).
+			Int("pid", proc.PID()).
+			Msg("RuneLite started from the API")
+		return api.LaunchResponse{
+			Account:   account.Name(),
+			Character: character.DisplayName,
+			PID:       proc.PID(),
+			LogFile:   proc.LogFile(),
+		}, nil
+	}, stop
+}
+
+func serveMetrics(ctx context.Context, l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	err := srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+var systemdUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=osrs-launcher session keeper
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+ExecStart={{ .Exec }} serve --interval {{ .Interval }} --log-human=false --log-level info
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=default.target
+`))
+
+// systemdQuoteExec quotes the executable of a unit's command line and
+// escapes its % specifiers. systemd refuses executables with quotes,
+// backslashes or control characters in their path, even quoted.
+func systemdQuoteExec(path string) (string, error) {
+	if strings.ContainsFunc(path, func(r rune) bool { return r == '"' || r == '\\' || unicode.IsControl(r) }) {
+		return "", fmt.Errorf("systemd cannot run %q, move it to a path without quotes or backslashes", path)
+	}
+	return `"` + strings.ReplaceAll(path, "%", "%%") + `"`, nil
+}
+
+func (r *Root) systemdUnit() *serpent.Command {
+	var (
+		interval time.Duration
+		install  bool
+	)
+
+	return &serpent.Command{
+		Use:   "systemd-unit",
+		Short: "Print a systemd user unit that runs the session keeper.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Interval",
+				Description: "Interval passed to serve.",
+				Flag:        "interval",
+				Default:     "15m",
+				Value:       serpent.DurationOf(&interval),
+			},
+			{
+				Name:        "Install",
+				Description: "Write the unit to ~/.config/systemd/user/osrs-launcher.service instead of printing it.",
+				Flag:        "install",
+				Default:     "false",
+				Value:       serpent.BoolOf(&install),
+			},
+		},
+		Middleware: r.LoggerMW(),
+		Handler: func(i *serpent.Invocation) error {
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("finding executable: %w", err)
+			}
+
+			quoted, err := systemdQuoteExec(exe)
+			if err != nil {
+				return err
+			}
+			var unit strings.Builder
+			err = systemdUnitTemplate.Execute(&unit, map[string]string{
+				"Exec":     quoted,
+				"Interval": interval.String(),
+			})
+			if err != nil {
+				return err
+			}
+			if !install {
+				_, _ = fmt.Fprint(i.Stdout, unit.String())
+				return nil
+			}
+
+			configDir, err := os.UserConfigDir()
+			if err != nil {
+				return fmt.Errorf("finding config dir: %w", err)
+			}
+			path := filepath.Join(configDir, "systemd", "user", "osrs-launcher.service")
+			err = config.File(path).WriteAtomic([]byte(unit.String()))
+			if err != nil {
+				return fmt.Errorf("writing unit: %w", err)
+			}
+			log.Info().
+				Str("unit", path).
+				Msg("Unit installed, enable it with 'systemctl --user enable --now osrs-launcher'")
+			return nil
+		},
+	}
+}
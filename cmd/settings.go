@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/rs/zerolog/log"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) Settings() *serpent.Command {
+	var settings config.AccountSettings
+
+	return &serpent.Command{
+		Use:   "settings <account>",
+		Short: "Show or change the settings of a saved account.",
+		Long: "Only the flags that are given are changed, an empty value clears a setting. " +
+			"The email, or the first line printed by the email command, pre-fills the login page.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Label",
+				Description: "A name for the account that is easier to recognize than its display name.",
+				Flag:        "label",
+				Value:       serpent.StringOf(&settings.Label),
+			},
+			{
+				Name:        "Email",
+				Description: "Email the account logs in with.",
+				Flag:        "email",
+				Value:       serpent.StringOf(&settings.Email),
+			},
+			{
+				Name:        "Email Command",
+				Description: "Shell command printing the email when --email is not set, like 'pass show jagex/main | sed -n 2p'.",
+				Flag:        "email-command",
+				Value:       serpent.StringOf(&settings.EmailCommand),
+			},
+			{
+				Name:        "Proxy",
+				Description: "Proxy URL for the account's requests, like socks5://127.0.0.1:1080.",
+				Flag:        "proxy",
+				Value:       serpent.StringOf(&settings.Proxy),
+			},
+			{
+				Name:        "Default Character",
+				Description: "Character launched when none is given.",
+				Flag:        "default-character",
+				Value:       serpent.StringOf(&settings.DefaultCharacter),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), serpent.RequireNArgs(1)),
+		Handler: func(i *serpent.Invocation) error {
+			root := config.DefaultDir().Init()
+			account := root.Account(i.Args[0])
+			if !account.Exists() {
+				return fmt.Errorf("no saved account %q", account.Name())
+			}
+			saved, err := account.Settings()
+			if err != nil {
+				return fmt.Errorf("loading settings: %w", err)
+			}
+
+			flags := i.ParsedFlags()
+			changes := map[string]func(){
+				"label":             func() { saved.Label = settings.Label },
+				"email":             func() { saved.Email = settings.Email },
+				"email-command":     func() { saved.EmailCommand = settings.EmailCommand },
+				"proxy":             func() { saved.Proxy = settings.Proxy },
+				"default-character": func() { saved.DefaultCharacter = settings.DefaultCharacter },
+			}
+			changed := false
+			for flag, apply := range changes {
+				if flags.Changed(flag) {
+					apply()
+					changed = true
+				}
+			}
+			if changed {
+				err = account.SaveSettings(saved)
+				if err != nil {
+					return fmt.Errorf("saving settings: %w", err)
+				}
+				log.Info().Str("account", account.Name()).Msg("Settings saved")
+			}
+
+			tw := tabwriter.NewWriter(i.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintf(tw, "label\t%s\n", orDash(saved.Label))
+			_, _ = fmt.Fprintf(tw, "email\t%s\n", orDash(saved.Email))
+			_, _ = fmt.Fprintf(tw, "email command\t%s\n", orDash(saved.EmailCommand))
+			_, _ = fmt.Fprintf(tw, "user id\t%s\n", orDash(saved.UserID))
+			_, _ = fmt.Fprintf(tw, "proxy\t%s\n", orDash(saved.Proxy))
+			_, _ = fmt.Fprintf(tw, "default character\t%s\n", orDash(saved.DefaultCharacter))
+			return tw.Flush()
+		},
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) Token() *serpent.Command {
+	cmd := &serpent.Command{
+		Use:   "token",
+		Short: "Look into the tokens saved for an account.",
+	}
+	cmd.AddSubcommands(
+		r.tokenInspect(),
+	)
+	return cmd
+}
+
+func (r *Root) tokenInspect() *serpent.Command {
+	var (
+		asJSON bool
+		jwks   string
+	)
+
+	return &serpent.Command{
+		Use:   "inspect <account>",
+		Short: "Decode an account's tokens without network access.",
+		Long: "Shows the claims of the ID token, the game ID token and the access token, and whether their " +
+			"signatures verify against the cached Jagex keys. Opaque tokens can only be checked by Jagex.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "JSON",
+				Description: "Print the tokens as JSON.",
+				Flag:        "json",
+				Default:     "false",
+				Value:       serpent.BoolOf(&asJSON),
+			},
+			{
+				Name:        "JWKS",
+				Description: "Key set file to verify signatures with, instead of the cached one.",
+				Flag:        "jwks",
+				Value:       serpent.StringOf(&jwks),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), serpent.RequireNArgs(1)),
+		Handler: func(i *serpent.Invocation) error {
+			root := config.DefaultDir().Init()
+			account := root.Account(i.Args[0])
+			if !account.Exists() {
+				return fmt.Errorf("no saved account %q", account.Name())
+			}
+			token, err := account.Token()
+			if err != nil {
+				return fmt.Errorf("getting token from save: %w", err)
+			}
+
+			var keys *jose.JSONWebKeySet
+			if jwks != "" {
+				var set jose.JSONWebKeySet
+				err = config.File(jwks).ReadJSON(&set)
+				if err != nil {
+					return fmt.Errorf("reading key set: %w", err)
+				}
+				keys = &set
+			} else {
+				keys, err = auth.ReadCachedKeySet(root.CacheDir())
+				if os.IsNotExist(err) {
+					// Nothing cached yet, signatures show as unchecked.
+					keys, err = nil, nil
+				}
+				if err != nil {
+					return fmt.Errorf("reading cached keys: %w", err)
+				}
+			}
+
+			infos := token.Inspect(keys)
+			if asJSON {
+				enc := json.NewEncoder(i.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(infos)
+			}
+			return writeTokenInfos(i.Stdout, infos, time.Now())
+		},
+	}
+}
+
+func writeTokenInfos(w io.Writer, infos []auth.TokenInfo, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for n, info := range infos {
+		if n > 0 {
+			_, _ = fmt.Fprintln(tw)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\n", strings.ToUpper(info.Name))
+		switch {
+		case !info.Present:
+			_, _ = fmt.Fprintln(tw, "  not saved")
+			continue
+		case info.Error != "":
+			_, _ = fmt.Fprintf(tw, "  error\t%s\n", info.Error)
+			continue
+		case info.Signature == auth.SignatureOpaque:
+			_, _ = fmt.Fprintln(tw, "  opaque, not a JWT")
+			continue
+		}
+		_, _ = fmt.Fprintf(tw, "  issuer\t%s\n", orDash(info.Issuer))
+		_, _ = fmt.Fprintf(tw, "  audience\t%s\n", orDash(strings.Join(info.Audience, ", ")))
+		_, _ = fmt.Fprintf(tw, "  authorized party\t%s\n", orDash(info.AZP))
+		_, _ = fmt.Fprintf(tw, "  subject\t%s\n", orDash(info.Subject))
+		_, _ = fmt.Fprintf(tw, "  amr\t%s\n", orDash(strings.Join(info.AMR, ", ")))
+		_, _ = fmt.Fprintf(tw, "  nonce\t%s\n", orDash(info.Nonce))
+		_, _ = fmt.Fprintf(tw, "  issued\t%s\n", formatTime(info.IssuedAt, now))
+		_, _ = fmt.Fprintf(tw, "  auth time\t%s\n", formatTime(info.AuthTime, now))
+		expiry := formatTime(info.Expiry, now)
+		if info.Expired(now) {
+			expiry += " (expired)"
+		}
+		_, _ = fmt.Fprintf(tw, "  expires\t%s\n", expiry)
+		_, _ = fmt.Fprintf(tw, "  key\t%s %s\n", orDash(info.Algorithm), info.KeyID)
+		_, _ = fmt.Fprintf(tw, "  signature\t%s\n", info.Signature)
+	}
+	return tw.Flush()
+}
+
+func formatTime(t *time.Time, now time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	d := t.Sub(now).Round(time.Second)
+	if d < 0 {
+		return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), -d)
+	}
+	return fmt.Sprintf("%s (in %s)", t.Format(time.RFC3339), d)
+}
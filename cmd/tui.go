@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/Emyrk/osrs-launcher/internal/tui"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) TUI() *serpent.Command {
+	var (
+		noProxy  bool
+		interval time.Duration
+		client   string
+		sup      supervision
+	)
+
+	return &serpent.Command{
+		Use:   "tui",
+		Short: "Full screen dashboard of every account, its characters and session health.",
+		Long: "Sessions are checked in the background like 'serve' does. Clients launched from the " +
+			"dashboard are supervised until it exits.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Interval",
+				Description: "How often to refresh and validate every account.",
+				Flag:        "interval",
+				Default:     "15m",
+				Value:       serpent.DurationOf(&interval),
+			},
+			{
+				Name:        "Client",
+				Description: "RuneLite to start, see launch --client.",
+				Flag:        "client",
+				Value:       serpent.StringOf(&client),
+			},
+			{
+				Name:        "Restart",
+				Description: "Restart launched clients when they exit abnormally.",
+				Flag:        "restart",
+				Default:     "false",
+				Value:       serpent.BoolOf(&sup.restart),
+			},
+			{
+				Name:        "Max Restarts",
+				Description: "Give up after this many restarts in a row, 0 is unlimited.",
+				Flag:        "max-restarts",
+				Default:     "5",
+				Value:       serpent.Int64Of(&sup.maxRestarts),
+			},
+			{
+				Name:        "Disable Proxy",
+				Description: "Flag to force disable use of the proxychains configuration.",
+				Flag:        "no-proxy",
+				Default:     "false",
+				Value:       serpent.BoolOf(&noProxy),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), UseProxy),
+		Handler: func(i *serpent.Invocation) error {
+			ctx, stop := signal.NotifyContext(i.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if interval <= 0 {
+				return fmt.Errorf("interval must be positive")
+			}
+
+			// Logs go to the dashboard's log pane while it owns the screen.
+			logs := tui.NewLogWriter()
+			previous := log.Logger
+			log.Logger = log.Logger.Output(zerolog.ConsoleWriter{Out: logs, NoColor: true, TimeFormat: time.Kitchen})
+			defer func() { log.Logger = previous }()
+
+			root := config.DefaultDir().Init()
+			k := keeper.New(root, interval)
+			keeperCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() { _ = k.Run(keeperCtx) }()
+
+			launch, stopClients := apiLauncher(ctx, root, client, sup)
+			defer stopClients()
+			d := &tui.Dashboard{
+				Root:   root,
+				Keeper: k,
+				Logs:   logs,
+				Launch: launch,
+				Use: func(_ context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error {
+					return useCharacter(account, token, character)
+				},
+			}
+			return d.Run(ctx)
+		},
+	}
+}
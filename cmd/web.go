@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/Emyrk/osrs-launcher/internal/web"
+	"github.com/rs/zerolog/log"
+
+	"github.com/coder/serpent"
+)
+
+func (r *Root) Web() *serpent.Command {
+	var (
+		noProxy bool
+		listen  string
+		client  string
+		sup     supervision
+	)
+
+	return &serpent.Command{
+		Use:   "web",
+		Short: "Manage accounts from a browser.",
+		Long: "Serves a small UI on localhost to add accounts, grant consent, switch the character " +
+			"RuneLite logs in as and launch clients. The URL printed at start holds a random token " +
+			"that every request needs, it changes on every run.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "Listen",
+				Description: "Address to serve the UI on. Port 0 picks a free port.",
+				Flag:        "listen",
+				Env:         "OSRS_LAUNCHER_WEB_LISTEN",
+				Default:     "127.0.0.1:0",
+				Value:       serpent.StringOf(&listen),
+			},
+			{
+				Name:        "Client",
+				Description: "RuneLite to start, see launch --client.",
+				Flag:        "client",
+				Value:       serpent.StringOf(&client),
+			},
+			{
+				Name:        "Restart",
+				Description: "Restart launched clients when they exit abnormally.",
+				Flag:        "restart",
+				Default:     "false",
+				Value:       serpent.BoolOf(&sup.restart),
+			},
+			{
+				Name:        "Max Restarts",
+				Description: "Give up after this many restarts in a row, 0 is unlimited.",
+				Flag:        "max-restarts",
+				Default:     "5",
+				Value:       serpent.Int64Of(&sup.maxRestarts),
+			},
+			{
+				Name:        "Disable Proxy",
+				Description: "Flag to force disable use of the proxychains configuration.",
+				Flag:        "no-proxy",
+				Default:     "false",
+				Value:       serpent.BoolOf(&noProxy),
+			},
+		},
+		Middleware: serpent.Chain(r.LoggerMW(), UseProxy),
+		Handler: func(i *serpent.Invocation) error {
+			ctx, stop := signal.NotifyContext(i.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			root := config.DefaultDir().Init()
+			// Only used for its checks and locks, the UI refreshes on demand.
+			k := keeper.New(root, time.Hour)
+			srv, err := web.New(ctx, root, k)
+			if err != nil {
+				return err
+			}
+			launch, stopClients := apiLauncher(ctx, root, client, sup)
+			defer stopClients()
+			srv.Launch = launch
+			srv.Use = func(_ context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error {
+				return useCharacter(account, token, character)
+			}
+
+			l, err := net.Listen("tcp", listen)
+			if err != nil {
+				return fmt.Errorf("listening on %s: %w", listen, err)
+			}
+			if addr, ok := l.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+				log.Warn().Str("listen", listen).Msg("The web UI is reachable from other machines, only the token protects it")
+			}
+			go k.CheckAll(ctx)
+
+			log.Info().Msg("Open the web UI at the URL below, it stops when this command does.")
+			_, _ = fmt.Fprintln(i.Stdout, srv.URL(l.Addr()))
+			return srv.Serve(ctx, l)
+		},
+	}
+}
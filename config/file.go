@@ -41,13 +41,72 @@ func (r Root) Accounts() ([]Account, error) {
 
 	var accounts []Account
 	for _, entry := range entries {
-		if entry.IsDir() {
-			accounts = append(accounts, Account(filepath.Join(string(r), entry.Name())))
+		if !entry.IsDir() {
+			continue
+		}
+		// The root also holds launcher state, only directories with a saved
+		// token are accounts.
+		account := Account(filepath.Join(string(r), entry.Name()))
+		if account.tokenFile().Exists() {
+			accounts = append(accounts, account)
 		}
 	}
 	return accounts, nil
 }
 
+// Home returns the isolated RuneLite home directory for a character. It is
+// used as the client's user.home, so RuneLite keeps its files in the
+// ".runelite" directory inside it.
+func (r Root) Home(characterID string) Home {
+	r.mustNotEmpty()
+	return Home(filepath.Join(string(r), "homes", characterID))
+}
+
+// StateDir holds the state and pid files of supervised clients.
+func (r Root) StateDir() string {
+	r.mustNotEmpty()
+	return filepath.Join(string(r), "state")
+}
+
+// LogDir holds the logs of supervised clients.
+func (r Root) LogDir() string {
+	r.mustNotEmpty()
+	return filepath.Join(string(r), "logs")
+}
+
+// StatusFile is where the session keeper reports the state of every
+// account.
+func (r Root) StatusFile() File {
+	r.mustNotEmpty()
+	return File(filepath.Join(string(r), "status.json"))
+}
+
+// CacheDir holds data fetched from Jagex that can be fetched again, like its
+// OpenID configuration and signing keys.
+func (r Root) CacheDir() string {
+	r.mustNotEmpty()
+	return filepath.Join(string(r), "cache")
+}
+
+// SocketPath is where the session keeper serves its API.
+func (r Root) SocketPath() string {
+	r.mustNotEmpty()
+	return filepath.Join(string(r), "api.sock")
+}
+
+type Home string
+
+// ConfigDir is the ".runelite" directory of the home.
+func (h Home) ConfigDir() string {
+	return filepath.Join(string(h), ".runelite")
+}
+
+// Exists reports whether the home has been created.
+func (h Home) Exists() bool {
+	_, err := os.Stat(h.ConfigDir())
+	return err == nil
+}
+
 type Account string
 
 func (a Account) Delete() error {
@@ -58,14 +117,23 @@ func (a Account) Name() string {
 	return filepath.Base(string(a))
 }
 
+// Exists reports whether the account has a saved token.
+func (a Account) Exists() bool {
+	return a.tokenFile().Exists()
+}
+
+func (a Account) tokenFile() File {
+	return File(filepath.Join(string(a), "token"))
+}
+
 func (a Account) Token() (auth.JagexAccountAuth, error) {
 	var token auth.JagexAccountAuth
-	err := File(filepath.Join(string(a), "token")).ReadJSON(&token)
+	err := a.tokenFile().ReadJSON(&token)
 	return token, err
 }
 
 func (a Account) SaveToken(token *auth.JagexAccountAuth) error {
-	return File(filepath.Join(string(a), "token")).WriteJSON(token)
+	return a.tokenFile().WriteJSON(token)
 }
 
 // File provides convenience methods for interacting with *os.File.
@@ -106,6 +174,63 @@ func (f File) WriteJSON(obj interface{}) error {
 	return write(string(f), 0o600, data)
 }
 
+// WriteAtomic replaces the file contents by writing to a temporary file in the
+// same directory and renaming it over the original. Readers never observe a
+// partially written file.
+func (f File) WriteAtomic(dat []byte) error {
+	return f.WriteAtomicMode(dat, 0o600)
+}
+
+// WriteAtomicMode is WriteAtomic with the file's permissions.
+func (f File) WriteAtomicMode(dat []byte, perm os.FileMode) error {
+	if f == "" {
+		return xerrors.Errorf("empty file path")
+	}
+	path := string(f)
+	err := os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Clean up on any failure, this is a no-op after the rename.
+	defer os.Remove(tmp.Name())
+
+	err = tmp.Chmod(perm)
+	if err == nil {
+		_, err = tmp.Write(dat)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Backup copies the file to "<file>.bak", replacing any older backup. It is
+// not an error if the file does not exist.
+func (f File) Backup() error {
+	if f == "" {
+		return xerrors.Errorf("empty file path")
+	}
+	byt, err := os.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return File(string(f) + ".bak").WriteAtomic(byt)
+}
+
 // Read reads the file to a string. All leading and trailing whitespace
 // is removed.
 func (f File) Read() (string, error) {
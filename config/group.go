@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Group is a named set of characters that are launched together.
+type Group struct {
+	Members []GroupMember `json:"members"`
+}
+
+// GroupMember is a character of a saved account. An empty Character means
+// the account's only character.
+type GroupMember struct {
+	Account   string `json:"account"`
+	Character string `json:"character,omitempty"`
+}
+
+// ParseGroupMember parses "account" or "account/character".
+func ParseGroupMember(s string) (GroupMember, error) {
+	account, character, _ := strings.Cut(s, "/")
+	if account == "" {
+		return GroupMember{}, fmt.Errorf("member %q has no account", s)
+	}
+	return GroupMember{Account: account, Character: character}, nil
+}
+
+func (m GroupMember) String() string {
+	if m.Character == "" {
+		return m.Account
+	}
+	return m.Account + "/" + m.Character
+}
+
+func (r Root) groupsFile() File {
+	r.mustNotEmpty()
+	return File(filepath.Join(string(r), "groups.json"))
+}
+
+// Groups returns the saved groups by name.
+func (r Root) Groups() (map[string]Group, error) {
+	groups := make(map[string]Group)
+	err := r.groupsFile().ReadJSON(&groups)
+	if os.IsNotExist(err) {
+		return groups, nil
+	}
+	return groups, err
+}
+
+// Group returns a single saved group.
+func (r Root) Group(name string) (Group, error) {
+	groups, err := r.Groups()
+	if err != nil {
+		return Group{}, err
+	}
+	group, ok := groups[name]
+	if !ok {
+		return Group{}, fmt.Errorf("no group named %q", name)
+	}
+	return group, nil
+}
+
+func (r Root) SaveGroups(groups map[string]Group) error {
+	return r.groupsFile().WriteJSON(groups)
+}
+
+// GroupNames returns the sorted names of groups.
+func GroupNames(groups map[string]Group) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
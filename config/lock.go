@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// lockPoll is how often Lock retries a lock held by another process.
+const lockPoll = 100 * time.Millisecond
+
+// Lock takes the account's lock file, which every launcher process holds
+// while it refreshes and saves the token. Refresh tokens rotate, two
+// processes refreshing at once leave one of them with a revoked token. Lock
+// waits until the lock is free or ctx is done and returns the unlock
+// function.
+func (a Account) Lock(ctx context.Context) (func(), error) {
+	path := filepath.Join(string(a), "token.lock")
+	for {
+		unlock, ok, err := tryLock(path)
+		if err != nil {
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if ok {
+			return unlock, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
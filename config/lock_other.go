@@ -0,0 +1,31 @@
+//go:build !unix
+
+package config
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// staleLock is how old a lock file must be before tryLock assumes the
+// process holding it died. Refreshing a token takes seconds.
+const staleLock = 5 * time.Minute
+
+// tryLock creates path exclusively, whoever created it holds the lock until
+// it is removed again.
+func tryLock(path string) (func(), bool, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		st, statErr := os.Stat(path)
+		if statErr == nil && time.Since(st.ModTime()) > staleLock {
+			_ = os.Remove(path)
+		}
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	_ = f.Close()
+	return func() { _ = os.Remove(path) }, true, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAccountLock(t *testing.T) {
+	account := Account(t.TempDir())
+	ctx := context.Background()
+
+	unlock, err := account.Lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second lock, like one from another process, waits for the first.
+	short, cancel := context.WithTimeout(ctx, 3*lockPoll)
+	defer cancel()
+	_, err = account.Lock(short)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v while locked, want %v", err, context.DeadlineExceeded)
+	}
+
+	go func() {
+		time.Sleep(lockPoll)
+		unlock()
+	}()
+	unlockAgain, err := account.Lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockAgain()
+}
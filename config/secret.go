@@ -0,0 +1,164 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv names the environment variable holding the passphrase
+// secrets are encrypted with. It is never written to disk, so reading the
+// config directory alone does not reveal them.
+const PassphraseEnv = "OSRS_LAUNCHER_PASSPHRASE"
+
+// ErrNoPassphrase is returned when a secret has to be encrypted or decrypted
+// without PassphraseEnv set.
+var ErrNoPassphrase = fmt.Errorf("set %s to encrypt and decrypt authenticator seeds", PassphraseEnv)
+
+const (
+	// sealedPrefix marks a value sealed with the passphrase, and the format it
+	// was sealed in: base64 of salt, nonce and ciphertext.
+	sealedPrefix = "v2:"
+	// legacyPrefix marks a value sealed with the key in secret.key, which
+	// earlier versions generated next to the accounts.
+	legacyPrefix = "v1:"
+
+	saltSize = 16
+)
+
+// passphrase returns the passphrase from the environment.
+func passphrase() (string, error) {
+	pass := os.Getenv(PassphraseEnv)
+	if pass == "" {
+		return "", ErrNoPassphrase
+	}
+	return pass, nil
+}
+
+// passphraseAEAD derives an AES-256 key from the passphrase and salt.
+func passphraseAEAD(pass string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with AES-GCM under a key derived from the
+// passphrase and a fresh salt.
+func seal(plaintext string) (string, error) {
+	pass, err := passphrase()
+	if err != nil {
+		return "", err
+	}
+	salt := make([]byte, saltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	aead, err := passphraseAEAD(pass, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(append(salt, nonce...), nonce, []byte(plaintext), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// unseal decrypts a value from seal, or one sealed with the root's legacy
+// key file.
+func (r Root) unseal(sealed string) (string, error) {
+	sealed = strings.TrimSpace(sealed)
+	if strings.HasPrefix(sealed, legacyPrefix) {
+		return r.unsealLegacy(sealed)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("sealed value is not base64: %w", err)
+	}
+	pass, err := passphrase()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < saltSize {
+		return "", fmt.Errorf("sealed value is too short")
+	}
+	aead, err := passphraseAEAD(pass, data[:saltSize])
+	if err != nil {
+		return "", err
+	}
+	data = data[saltSize:]
+	if len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("sealed value is too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt, %s may be wrong: %w", PassphraseEnv, err)
+	}
+	return string(plaintext), nil
+}
+
+// legacyKeyFile held the key of values sealed by earlier versions.
+func (r Root) legacyKeyFile() string {
+	r.mustNotEmpty()
+	return filepath.Join(string(r), "secret.key")
+}
+
+// unsealLegacy decrypts a value sealed with the key in secret.key. Callers
+// seal it again with the passphrase, after which the key file can go.
+func (r Root) unsealLegacy(sealed string) (string, error) {
+	path := r.legacyKeyFile()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("legacy secret key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return "", fmt.Errorf("%s is not a 32 byte base64 key", path)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, legacyPrefix))
+	if err != nil {
+		return "", fmt.Errorf("sealed value is not base64: %w", err)
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("sealed value is too short")
+	}
+	plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt, %s may have changed: %w", path, err)
+	}
+	return string(plaintext), nil
+}
+
+// isSealed reports whether s came from seal.
+func isSealed(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), sealedPrefix)
+}
+
+// isLegacySealed reports whether s was sealed with the legacy key file.
+func isLegacySealed(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), legacyPrefix)
+}
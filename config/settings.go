@@ -0,0 +1,92 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+)
+
+// AccountSettings are optional preferences of a saved account.
+type AccountSettings struct {
+	// Label is a name for the account that is easier to recognize than its
+	// Jagex display name.
+	Label string `json:"label,omitempty"`
+	// Email is the address the account logs in with.
+	Email string `json:"email,omitempty"`
+	// EmailCommand prints the email when Email is empty, for example
+	// "pass show jagex/main | grep ^email: | cut -d' ' -f2". It is run with sh.
+	EmailCommand string `json:"email_command,omitempty"`
+	// UserID is the Jagex user ID, recorded at login.
+	UserID string `json:"user_id,omitempty"`
+	// Proxy is a proxy URL used for every request of the account instead of
+	// the proxychains configuration.
+	Proxy string `json:"proxy,omitempty"`
+	// DefaultCharacter is launched when no character is given.
+	DefaultCharacter string `json:"default_character,omitempty"`
+}
+
+func (a Account) settingsFile() File {
+	return File(filepath.Join(string(a), "settings.json"))
+}
+
+// Settings returns the account's settings, empty if none were saved.
+func (a Account) Settings() (AccountSettings, error) {
+	var settings AccountSettings
+	err := a.settingsFile().ReadJSON(&settings)
+	if os.IsNotExist(err) {
+		return AccountSettings{}, nil
+	}
+	return settings, err
+}
+
+func (a Account) SaveSettings(settings AccountSettings) error {
+	return a.settingsFile().WriteJSON(settings)
+}
+
+// Context returns ctx set up for the account's requests, going through its
+// proxy when it has one.
+func (a Account) Context(ctx context.Context) (context.Context, error) {
+	settings, err := a.Settings()
+	if err != nil {
+		return nil, fmt.Errorf("loading settings: %w", err)
+	}
+	if settings.Proxy == "" {
+		return ctx, nil
+	}
+	return auth.WithProxy(ctx, settings.Proxy)
+}
+
+// LoginHint is what pre-fills the login page: the email, the output of the
+// email command or the user ID, whichever is set first.
+func (s AccountSettings) LoginHint(ctx context.Context) (string, error) {
+	if s.Email != "" {
+		return s.Email, nil
+	}
+	if s.EmailCommand != "" {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", s.EmailCommand)
+		// Password managers may ask for a passphrase on the terminal.
+		cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("email command: %w", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				return line, nil
+			}
+		}
+		return "", fmt.Errorf("email command printed nothing")
+	}
+	return s.UserID, nil
+}
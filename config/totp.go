@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/Emyrk/osrs-launcher/internal/totp"
+	"github.com/rs/zerolog/log"
+)
+
+func (a Account) totpFile() File {
+	return File(filepath.Join(string(a), "totp"))
+}
+
+// root is the config root the account is saved in.
+func (a Account) root() Root {
+	return Root(filepath.Dir(string(a)))
+}
+
+// TOTP returns the account's authenticator seed. ok is false when none is
+// saved. Seeds saved in plaintext or with the legacy key file by earlier
+// versions are encrypted with the passphrase on the way, when it is set.
+func (a Account) TOTP() (key totp.Key, ok bool, err error) {
+	seed, err := a.totpFile().Read()
+	if os.IsNotExist(err) {
+		return totp.Key{}, false, nil
+	}
+	if err != nil {
+		return totp.Key{}, false, err
+	}
+	reseal := !isSealed(seed)
+	if isSealed(seed) || isLegacySealed(seed) {
+		seed, err = a.root().unseal(seed)
+		if err != nil {
+			return totp.Key{}, false, err
+		}
+	}
+	if reseal {
+		err := a.SaveTOTP(seed)
+		if err != nil {
+			log.Warn().Err(err).Str("account", a.Name()).Msg("Cannot encrypt the authenticator seed with the passphrase")
+		}
+	}
+	key, err = totp.Parse(seed)
+	return key, err == nil, err
+}
+
+// SaveTOTP saves an authenticator seed, a base32 secret or otpauth:// URI.
+// It is encrypted with the passphrase from PassphraseEnv and only readable
+// by the current user, like the token.
+func (a Account) SaveTOTP(seed string) error {
+	_, err := totp.Parse(seed)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(seed)
+	if err != nil {
+		return err
+	}
+	return a.totpFile().Write(sealed)
+}
+
+func (a Account) DeleteTOTP() error {
+	err := a.totpFile().Delete()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSeed = "JBSWY3DPEHPK3PXP"
+
+func testAccount(t *testing.T) Account {
+	t.Helper()
+	account := Root(t.TempDir()).Account("main")
+	err := os.MkdirAll(string(account), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+func TestSaveTOTP(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	account := testAccount(t)
+	_, ok, err := account.TOTP()
+	if err != nil || ok {
+		t.Fatalf("got ok %v, %v without a seed", ok, err)
+	}
+
+	err = account.SaveTOTP(testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := account.totpFile().Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, sealedPrefix) || strings.Contains(stored, testSeed) {
+		t.Errorf("seed is not encrypted on disk: %q", stored)
+	}
+	info, err := os.Stat(string(account.totpFile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("totp has mode %v, want 0600", info.Mode().Perm())
+	}
+	_, err = os.Stat(account.root().legacyKeyFile())
+	if !os.IsNotExist(err) {
+		t.Errorf("a key file was written next to the accounts: %v", err)
+	}
+
+	key, ok, err := account.TOTP()
+	if err != nil || !ok {
+		t.Fatalf("got ok %v, %v", ok, err)
+	}
+	now := time.Now()
+	code, err := key.Code(now)
+	if err != nil || code == "" || len(key.Secret) != 10 {
+		t.Errorf("got key %+v, code %q, %v", key, code, err)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	_, _, err = account.TOTP()
+	if err == nil {
+		t.Error("decrypted with the wrong passphrase")
+	}
+	t.Setenv(PassphraseEnv, "")
+	_, _, err = account.TOTP()
+	if !errors.Is(err, ErrNoPassphrase) {
+		t.Errorf("got %v without a passphrase, want %v", err, ErrNoPassphrase)
+	}
+	err = account.SaveTOTP(testSeed)
+	if !errors.Is(err, ErrNoPassphrase) {
+		t.Errorf("got %v saving without a passphrase, want %v", err, ErrNoPassphrase)
+	}
+	t.Setenv(PassphraseEnv, "correct horse")
+
+	err = account.SaveTOTP("not base32!")
+	if err == nil {
+		t.Error("saved an invalid seed")
+	}
+
+	err = account.DeleteTOTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = account.DeleteTOTP()
+	if err != nil {
+		t.Errorf("deleting a missing seed: %v", err)
+	}
+}
+
+func TestTOTPMigrated(t *testing.T) {
+	legacyKey := make([]byte, 32)
+	tests := []struct {
+		name   string
+		stored func(t *testing.T, account Account) string
+	}{
+		{name: "plaintext", stored: func(t *testing.T, account Account) string { return testSeed + "\n" }},
+		{name: "legacy key file", stored: func(t *testing.T, account Account) string {
+			err := File(account.root().legacyKeyFile()).Write(base64.StdEncoding.EncodeToString(legacyKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			aead, err := newAEAD(legacyKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nonce := make([]byte, aead.NonceSize())
+			return legacyPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(testSeed), nil))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := testAccount(t)
+			stored := tt.stored(t, account)
+			err := account.totpFile().Write(stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Without a passphrase the seed is still usable but stays as is.
+			t.Setenv(PassphraseEnv, "")
+			_, ok, err := account.TOTP()
+			if err != nil || !ok {
+				t.Fatalf("got ok %v, %v", ok, err)
+			}
+			got, err := account.totpFile().Read()
+			if err != nil || got != strings.TrimSpace(stored) {
+				t.Errorf("seed changed without a passphrase: %q, %v", got, err)
+			}
+
+			t.Setenv(PassphraseEnv, "correct horse")
+			_, ok, err = account.TOTP()
+			if err != nil || !ok {
+				t.Fatalf("got ok %v, %v", ok, err)
+			}
+			got, err = account.totpFile().Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isSealed(got) {
+				t.Errorf("seed was not encrypted with the passphrase: %q", got)
+			}
+		})
+	}
+}
+
+func TestSeal(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	root := Root(t.TempDir())
+	a, err := seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("sealing twice gave the same output, the salt or nonce is reused")
+	}
+	got, err := root.unseal(a)
+	if err != nil || got != "secret" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	tampered := a[:len(a)-2] + "AA"
+	if tampered == a {
+		tampered = a[:len(a)-2] + "BB"
+	}
+	_, err = root.unseal(tampered)
+	if err == nil {
+		t.Error("unsealed a tampered value")
+	}
+
+	t.Setenv(PassphraseEnv, "battery staple")
+	_, err = root.unseal(a)
+	if err == nil {
+		t.Error("unsealed with another passphrase")
+	}
+}
@@ -0,0 +1,365 @@
+// Package api is the local JSON API of the session keeper. It lets scripts
+// ask for valid game sessions and launch clients without driving the CLI.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/rs/zerolog/log"
+)
+
+// Character is a character of an account.
+type Character struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
+type Account struct {
+	Name        string       `json:"name"`
+	State       keeper.State `json:"state,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	CheckedAt   time.Time    `json:"checked_at,omitempty"`
+	TokenExpiry time.Time    `json:"token_expiry,omitempty"`
+	Characters  []Character  `json:"characters"`
+}
+
+type Session struct {
+	Account     string `json:"account"`
+	CharacterID string `json:"character_id"`
+	DisplayName string `json:"display_name"`
+	SessionID   string `json:"session_id"`
+}
+
+type LaunchRequest struct {
+	Account   string `json:"account"`
+	Character string `json:"character"`
+}
+
+type LaunchResponse struct {
+	Account   string `json:"account"`
+	Character string `json:"character"`
+	PID       int    `json:"pid"`
+	LogFile   string `json:"log_file,omitempty"`
+}
+
+type Health struct {
+	Status         string    `json:"status"`
+	LastCheck      time.Time `json:"last_check"`
+	Accounts       int       `json:"accounts"`
+	NeedsAttention []string  `json:"needs_attention"`
+	ProcessID      int       `json:"pid"`
+	ProcessStarted time.Time `json:"started_at"`
+}
+
+type Error struct {
+	Error string       `json:"error"`
+	State keeper.State `json:"state,omitempty"`
+}
+
+// Launcher starts a client for a character of an account that has a valid
+// session.
+type Launcher func(ctx context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) (LaunchResponse, error)
+
+type Server struct {
+	Root   config.Root
+	Keeper *keeper.Keeper
+	// Launch is optional, without it launching is not supported.
+	Launch Launcher
+	// Allow is optional. When set, requests only see the accounts it
+	// allows.
+	Allow func(r *http.Request, account string) bool
+
+	started time.Time
+}
+
+func New(root config.Root, k *keeper.Keeper, launch Launcher) *Server {
+	return &Server{Root: root, Keeper: k, Launch: launch, started: time.Now()}
+}
+
+// Handler routes:
+//
+//	GET  /v1/health
+//	GET  /v1/accounts
+//	GET  /v1/accounts/{account}
+//	GET  /v1/accounts/{account}/session?character={name or id}
+//	POST /v1/launch
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Debug().Str("method", r.Method).Str("path", r.URL.Path).Msg("api request")
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case len(parts) == 2 && parts[0] == "v1" && parts[1] == "health":
+			allow(w, r, http.MethodGet, s.health)
+		case len(parts) == 2 && parts[0] == "v1" && parts[1] == "accounts":
+			allow(w, r, http.MethodGet, s.accounts)
+		case len(parts) == 3 && parts[0] == "v1" && parts[1] == "accounts":
+			allow(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+				s.account(w, r, parts[2])
+			})
+		case len(parts) == 4 && parts[0] == "v1" && parts[1] == "accounts" && parts[3] == "session":
+			allow(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+				s.session(w, r, parts[2])
+			})
+		case len(parts) == 2 && parts[0] == "v1" && parts[1] == "launch":
+			allow(w, r, http.MethodPost, s.launch)
+		default:
+			writeError(w, http.StatusNotFound, Error{Error: "not found"})
+		}
+	})
+}
+
+func allow(w http.ResponseWriter, r *http.Request, method string, h http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, Error{Error: "method not allowed"})
+		return
+	}
+	h(w, r)
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	st := s.Keeper.Status()
+	for name := range st.Accounts {
+		if s.Allow != nil && !s.Allow(r, name) {
+			delete(st.Accounts, name)
+		}
+	}
+	h := Health{
+		Status:         "ok",
+		LastCheck:      st.UpdatedAt,
+		Accounts:       len(st.Accounts),
+		NeedsAttention: []string{},
+		ProcessID:      os.Getpid(),
+		ProcessStarted: s.started,
+	}
+	for name, acct := range st.Accounts {
+		if acct.State.NeedsHuman() {
+			h.NeedsAttention = append(h.NeedsAttention, name)
+		}
+	}
+	sort.Strings(h.NeedsAttention)
+	if len(h.NeedsAttention) > 0 {
+		h.Status = "degraded"
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+func (s *Server) accounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := s.Root.Accounts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, Error{Error: err.Error()})
+		return
+	}
+
+	status := s.Keeper.Status()
+	out := make([]Account, 0, len(accounts))
+	for _, account := range accounts {
+		if s.Allow != nil && !s.Allow(r, account.Name()) {
+			continue
+		}
+		acct, err := Describe(account, status)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, Error{Error: err.Error()})
+			return
+		}
+		out = append(out, acct)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) account(w http.ResponseWriter, r *http.Request, name string) {
+	account, ok := s.lookup(w, r, name)
+	if !ok {
+		return
+	}
+	acct, err := Describe(account, s.Keeper.Status())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, Error{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, acct)
+}
+
+// Describe reports an account from its saved token and the keeper's status
+// without touching the network.
+func Describe(account config.Account, status keeper.Status) (Account, error) {
+	token, err := account.Token()
+	if err != nil {
+		return Account{}, fmt.Errorf("loading %s: %w", account.Name(), err)
+	}
+	acct := Account{
+		Name:        account.Name(),
+		TokenExpiry: token.Token.Expiry,
+		Characters:  make([]Character, 0, len(token.Characters)),
+	}
+	if st, ok := status.Accounts[account.Name()]; ok {
+		acct.State = st.State
+		acct.Message = st.Message
+		acct.CheckedAt = st.CheckedAt
+	}
+	for _, c := range token.Characters {
+		acct.Characters = append(acct.Characters, Character{ID: c.AccountID, DisplayName: c.DisplayName})
+	}
+	return acct, nil
+}
+
+func (s *Server) session(w http.ResponseWriter, r *http.Request, name string) {
+	account, ok := s.lookup(w, r, name)
+	if !ok {
+		return
+	}
+	token, character, ok := s.validSession(w, r.Context(), account, r.URL.Query().Get("character"))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, Session{
+		Account:     account.Name(),
+		CharacterID: character.AccountID,
+		DisplayName: character.DisplayName,
+		SessionID:   token.Session,
+	})
+}
+
+func (s *Server) launch(w http.ResponseWriter, r *http.Request) {
+	var req LaunchRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, Error{Error: fmt.Sprintf("decoding request: %v", err)})
+		return
+	}
+	if s.Launch == nil {
+		writeError(w, http.StatusNotImplemented, Error{Error: "launching is not supported by this server"})
+		return
+	}
+
+	account, ok := s.lookup(w, r, req.Account)
+	if !ok {
+		return
+	}
+	token, character, ok := s.validSession(w, r.Context(), account, req.Character)
+	if !ok {
+		return
+	}
+	resp, err := s.Launch(r.Context(), account, token, character)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, Error{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request, name string) (config.Account, bool) {
+	// Account names are directory names, never let them walk the tree.
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		writeError(w, http.StatusBadRequest, Error{Error: "invalid account name"})
+		return "", false
+	}
+	if s.Allow != nil && !s.Allow(r, name) {
+		writeError(w, http.StatusForbidden, Error{Error: fmt.Sprintf("not allowed to use account %q", name)})
+		return "", false
+	}
+	account := s.Root.Account(name)
+	if !account.Exists() {
+		writeError(w, http.StatusNotFound, Error{Error: fmt.Sprintf("no account %q", name)})
+		return "", false
+	}
+	return account, true
+}
+
+// validSession refreshes the account if needed and finds the character.
+func (s *Server) validSession(w http.ResponseWriter, ctx context.Context, account config.Account, query string) (auth.JagexAccountAuth, auth.JagexCharacter, bool) {
+	token, st := s.Keeper.Session(ctx, account)
+	if st.State != keeper.StateOK {
+		code := http.StatusBadGateway
+		if st.State.NeedsHuman() {
+			code = http.StatusConflict
+		}
+		writeError(w, code, Error{Error: st.Message, State: st.State})
+		return token, auth.JagexCharacter{}, false
+	}
+
+	character, err := auth.FindCharacter(token.Characters, query)
+	if err != nil {
+		writeError(w, http.StatusNotFound, Error{Error: err.Error()})
+		return token, auth.JagexCharacter{}, false
+	}
+	return token, character, true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, e Error) {
+	writeJSON(w, code, e)
+}
+
+// ListenUnix listens on a Unix socket only the current user can connect to.
+// A stale socket left behind by a previous run is replaced, a live one is an
+// error.
+func ListenUnix(path string) (net.Listener, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", path)
+		}
+		_ = os.Remove(path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, 0o600)
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve serves the API on l until ctx is canceled.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err := srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+// Package broker shares a central account store with a team. The broker
+// server holds the refresh tokens and hands game sessions to clients that
+// authenticate with TLS client certificates, so workstations never store
+// tokens themselves.
+//
+// Jagex game sessions cannot be scoped or shortened, a client gets the
+// account's own session and it stays valid until Jagex ends it. Removing a
+// client from the ACL stops its next requests, not a session it already has.
+package broker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/api"
+	"github.com/rs/zerolog/log"
+)
+
+// ACL lists which accounts each client may use. Clients are identified by
+// the common name of their certificate.
+type ACL struct {
+	Clients map[string]ClientACL `json:"clients"`
+}
+
+type ClientACL struct {
+	// Accounts are account names or path.Match patterns, "*" allows all.
+	Accounts []string `json:"accounts"`
+}
+
+// LoadACL reads an ACL file.
+func LoadACL(file string) (ACL, error) {
+	var acl ACL
+	err := config.File(file).ReadJSON(&acl)
+	if err != nil {
+		return ACL{}, err
+	}
+	for client, c := range acl.Clients {
+		for _, pattern := range c.Accounts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return ACL{}, fmt.Errorf("client %q: bad pattern %q: %w", client, pattern, err)
+			}
+		}
+	}
+	return acl, nil
+}
+
+// Allowed reports whether the client may use the account.
+func (a ACL) Allowed(client, account string) bool {
+	c, ok := a.Clients[client]
+	if !ok {
+		return false
+	}
+	for _, pattern := range c.Accounts {
+		if ok, _ := path.Match(pattern, account); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditEntry is a line of the audit log.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Query      string    `json:"query,omitempty"`
+	Status     int       `json:"status"`
+}
+
+// Audit appends JSON lines to a file.
+type Audit struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func OpenAudit(file string) (*Audit, error) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Audit{file: f}, nil
+}
+
+func (a *Audit) Log(entry AuditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.file.Write(append(data, '\n'))
+	if err != nil {
+		log.Error().Err(err).Msg("writing audit log")
+	}
+}
+
+func (a *Audit) Close() error {
+	return a.file.Close()
+}
+
+type clientKey struct{}
+
+// ClientName returns the authenticated client of a broker request.
+func ClientName(r *http.Request) string {
+	name, _ := r.Context().Value(clientKey{}).(string)
+	return name
+}
+
+// Server wraps the local API with client certificate authentication, ACLs and
+// an audit log.
+type Server struct {
+	API   *api.Server
+	ACL   ACL
+	Audit *Audit
+}
+
+func (s *Server) Handler() http.Handler {
+	s.API.Allow = func(r *http.Request, account string) bool {
+		return s.ACL.Allowed(ClientName(r), account)
+	}
+	inner := s.API.Handler()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		client := ""
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			client = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		}
+
+		defer func() {
+			s.Audit.Log(AuditEntry{
+				Time:       time.Now(),
+				Client:     client,
+				RemoteAddr: r.RemoteAddr,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Query:      r.URL.RawQuery,
+				Status:     rec.status,
+			})
+		}()
+
+		if _, ok := s.ACL.Clients[client]; !ok {
+			rec.Header().Set("Content-Type", "application/json")
+			rec.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(rec).Encode(api.Error{Error: fmt.Sprintf("client %q is not in the ACL", client)})
+			return
+		}
+		inner.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), clientKey{}, client)))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// ServerTLSConfig requires every client to present a certificate signed by
+// the CA in caFile.
+func ServerTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("server certificate: %w", err)
+	}
+	pool, err := loadPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientTLSConfig presents the client certificate and trusts servers signed
+// by the CA in caFile, or the system roots if it is empty.
+func ClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("client certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile != "" {
+		cfg.RootCAs, err = loadPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+func loadPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA: no certificates in %s", caFile)
+	}
+	return pool, nil
+}
+
+// Serve serves the broker over TLS on addr until ctx is canceled.
+func (s *Server) Serve(ctx context.Context, addr string, tlsConfig *tls.Config) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err = srv.ServeTLS(l, "", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package broker
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/internal/api"
+)
+
+// Client talks to a broker server.
+type Client struct {
+	URL  *url.URL
+	HTTP *http.Client
+}
+
+func NewClient(rawURL string, tlsConfig *tls.Config) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("broker url: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("broker url must be https")
+	}
+	return &Client{
+		URL: u,
+		HTTP: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+				Proxy:           http.ProxyFromEnvironment,
+			},
+		},
+	}, nil
+}
+
+// Accounts lists the accounts this client may use.
+func (c *Client) Accounts(ctx context.Context) ([]api.Account, error) {
+	var accounts []api.Account
+	return accounts, c.get(ctx, "/v1/accounts", nil, &accounts)
+}
+
+// Account describes a single account.
+func (c *Client) Account(ctx context.Context, name string) (api.Account, error) {
+	var account api.Account
+	return account, c.get(ctx, "/v1/accounts/"+url.PathEscape(name), nil, &account)
+}
+
+// Session returns a valid game session for a character of the account.
+func (c *Client) Session(ctx context.Context, account, character string) (api.Session, error) {
+	var session api.Session
+	q := url.Values{}
+	if character != "" {
+		q.Set("character", character)
+	}
+	return session, c.get(ctx, "/v1/accounts/"+url.PathEscape(account)+"/session", q, &session)
+}
+
+// Token returns an account with a game session and its characters, shaped
+// like a locally stored one but without any OAuth tokens.
+func (c *Client) Token(ctx context.Context, account string) (auth.JagexAccountAuth, error) {
+	acct, err := c.Account(ctx, account)
+	if err != nil {
+		return auth.JagexAccountAuth{}, err
+	}
+	if len(acct.Characters) == 0 {
+		return auth.JagexAccountAuth{}, fmt.Errorf("account %q has no characters", account)
+	}
+
+	// A game session is shared by every character of the account.
+	session, err := c.Session(ctx, account, acct.Characters[0].ID)
+	if err != nil {
+		return auth.JagexAccountAuth{}, err
+	}
+	token := auth.JagexAccountAuth{Session: session.SessionID}
+	for _, ch := range acct.Characters {
+		// Character ids name directories and state files on this machine.
+		err = checkCharacterID(ch.ID)
+		if err != nil {
+			return auth.JagexAccountAuth{}, err
+		}
+		token.Characters = append(token.Characters, auth.JagexCharacter{
+			AccountID:   ch.ID,
+			DisplayName: ch.DisplayName,
+		})
+	}
+	return token, nil
+}
+
+// checkCharacterID rejects ids that are not a single file name.
+func checkCharacterID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`+"\x00") {
+		return fmt.Errorf("broker sent an invalid character id %q", id)
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, into any) error {
+	u := c.URL.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("broker: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr api.Error
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		msg := strings.TrimSpace(apiErr.Error)
+		if msg == "" {
+			msg = resp.Status
+		}
+		if apiErr.State != "" {
+			msg = fmt.Sprintf("%s (%s)", msg, apiErr.State)
+		}
+		return fmt.Errorf("broker: %s", msg)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
@@ -0,0 +1,61 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Emyrk/osrs-launcher/internal/api"
+)
+
+func TestClientToken(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		err  bool
+	}{
+		{name: "numeric", id: "123456"},
+		{name: "empty", id: "", err: true},
+		{name: "parent", id: "..", err: true},
+		{name: "separator", id: "../../etc", err: true},
+		{name: "backslash", id: `a\b`, err: true},
+		{name: "nul", id: "a\x00b", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/accounts/main":
+					_ = json.NewEncoder(w).Encode(api.Account{Name: "main", Characters: []api.Character{{ID: tt.id, DisplayName: "Zezima"}}})
+				case "/v1/accounts/main/session":
+					_ = json.NewEncoder(w).Encode(api.Session{Account: "main", CharacterID: tt.id, SessionID: "session"})
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &Client{URL: u, HTTP: srv.Client()}
+
+			token, err := c.Token(context.Background(), "main")
+			if tt.err {
+				if err == nil {
+					t.Fatalf("accepted character id %q", tt.id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Session != "session" || len(token.Characters) != 1 || token.Characters[0].AccountID != tt.id {
+				t.Errorf("got %+v", token)
+			}
+		})
+	}
+}
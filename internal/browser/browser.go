@@ -0,0 +1,81 @@
+// Package browser opens URLs in the user's web browser.
+package browser
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoBrowser is returned when there is no desktop session to open a
+// browser in.
+var ErrNoBrowser = errors.New("no desktop session to open a browser in")
+
+// Available reports whether Open can be expected to show a browser: $BROWSER
+// is set, or there is a desktop session.
+func Available() bool {
+	if os.Getenv("BROWSER") != "" {
+		return true
+	}
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// Open starts the browser on url without waiting for it to exit. $BROWSER is
+// used when set, a colon separated list of commands where %s is the url, and
+// the desktop's default browser otherwise.
+func Open(url string) error {
+	cmd, err := command(url)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = nil, nil
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	// Some browsers run until they are closed, only reap the process.
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+func command(url string) (*exec.Cmd, error) {
+	if env := os.Getenv("BROWSER"); env != "" {
+		for _, candidate := range strings.Split(env, ":") {
+			args := strings.Fields(candidate)
+			if len(args) == 0 {
+				continue
+			}
+			if _, err := exec.LookPath(args[0]); err != nil {
+				continue
+			}
+			substituted := false
+			for i, arg := range args {
+				if strings.Contains(arg, "%s") {
+					args[i] = strings.ReplaceAll(arg, "%s", url)
+					substituted = true
+				}
+			}
+			if !substituted {
+				args = append(args, url)
+			}
+			return exec.Command(args[0], args[1:]...), nil
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url), nil
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url), nil
+	}
+	if !Available() {
+		return nil, ErrNoBrowser
+	}
+	return exec.Command("xdg-open", url), nil
+}
@@ -0,0 +1,251 @@
+// Package keeper keeps the saved accounts' tokens and game sessions fresh in
+// the background, and records which accounts need a human to log in again.
+package keeper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+type State string
+
+const (
+	StateOK State = "ok"
+	// StateNeedsLogin means the refresh token was rejected, the account must
+	// go through auth again.
+	StateNeedsLogin State = "needs_login"
+	// StateNeedsConsent means there is no game session and no way to make
+	// one without the consent step of auth.
+	StateNeedsConsent State = "needs_consent"
+	// StateError is a failure that may go away on its own, like a network
+	// error.
+	StateError State = "error"
+)
+
+// NeedsHuman reports whether only the user can fix the state.
+func (s State) NeedsHuman() bool {
+	return s == StateNeedsLogin || s == StateNeedsConsent
+}
+
+type AccountStatus struct {
+	State       State     `json:"state"`
+	Message     string    `json:"message,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+	TokenExpiry time.Time `json:"token_expiry"`
+	Characters  []string  `json:"characters,omitempty"`
+}
+
+type Status struct {
+	UpdatedAt time.Time                `json:"updated_at"`
+	Accounts  map[string]AccountStatus `json:"accounts"`
+}
+
+type Keeper struct {
+	Root     config.Root
+	Config   *oauth2.Config
+	Interval time.Duration
+
+	mu     sync.Mutex
+	locks  map[string]*sync.Mutex
+	status Status
+}
+
+func New(root config.Root, interval time.Duration) *Keeper {
+	return &Keeper{
+		Root:     root,
+		Config:   auth.JagexOAuthConfig(),
+		Interval: interval,
+		locks:    make(map[string]*sync.Mutex),
+		status:   Status{Accounts: make(map[string]AccountStatus)},
+	}
+}
+
+// Status returns the result of the most recent checks.
+func (k *Keeper) Status() Status {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	st := Status{UpdatedAt: k.status.UpdatedAt, Accounts: make(map[string]AccountStatus, len(k.status.Accounts))}
+	for name, acct := range k.status.Accounts {
+		st.Accounts[name] = acct
+	}
+	return st
+}
+
+// Run checks every account once per interval until ctx is canceled.
+func (k *Keeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(k.Interval)
+	defer ticker.Stop()
+	for {
+		k.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll checks every saved account and writes the status file.
+func (k *Keeper) CheckAll(ctx context.Context) Status {
+	status := Status{
+		UpdatedAt: time.Now(),
+		Accounts:  make(map[string]AccountStatus),
+	}
+
+	accounts, err := k.Root.Accounts()
+	if err != nil {
+		log.Error().Err(err).Msg("listing accounts")
+	}
+	for _, account := range accounts {
+		st := k.Check(ctx, account)
+		status.Accounts[account.Name()] = st
+
+		event := log.Info()
+		if st.State.NeedsHuman() {
+			event = log.Warn()
+		} else if st.State == StateError {
+			event = log.Error()
+		}
+		event.
+			Str("account", account.Name()).
+			Str("state", string(st.State)).
+			Str("message", st.Message).
+			Msg("Account checked")
+	}
+
+	k.mu.Lock()
+	k.status = status
+	k.mu.Unlock()
+	err = k.Root.StatusFile().WriteAtomic(mustJSON(status))
+	if err != nil {
+		log.Error().Err(err).Msg("writing status file")
+	}
+	return status
+}
+
+// Check refreshes the account's token, makes sure its game session is valid
+// and saves the result.
+func (k *Keeper) Check(ctx context.Context, account config.Account) AccountStatus {
+	_, st := k.Session(ctx, account)
+	return st
+}
+
+// Session is Check that also returns the refreshed token, for callers that
+// need the game session itself.
+func (k *Keeper) Session(ctx context.Context, account config.Account) (auth.JagexAccountAuth, AccountStatus) {
+	st := AccountStatus{CheckedAt: time.Now()}
+	unlock, err := k.Lock(ctx, account)
+	if err != nil {
+		st.State, st.Message = StateError, err.Error()
+		return auth.JagexAccountAuth{}, st
+	}
+	defer unlock()
+
+	token, err := account.Token()
+	if err != nil {
+		st.State, st.Message = StateError, fmt.Sprintf("loading token: %v", err)
+		return token, st
+	}
+
+	accountCtx, err := account.Context(ctx)
+	if err != nil {
+		st.State, st.Message = StateError, err.Error()
+		return token, st
+	}
+	err = token.EnsureSession(accountCtx, k.Config)
+	if saveErr := SaveToken(account, token, err); saveErr != nil {
+		log.Error().Err(saveErr).Str("account", account.Name()).Msg("saving token to disk")
+	}
+	st.TokenExpiry = token.Token.Expiry
+	for _, c := range token.Characters {
+		st.Characters = append(st.Characters, c.DisplayName)
+	}
+
+	st.State = Classify(err)
+	if err != nil {
+		st.Message = err.Error()
+	}
+
+	k.mu.Lock()
+	k.status.Accounts[account.Name()] = st
+	k.mu.Unlock()
+	return token, st
+}
+
+// Classify maps an error from auth.EnsureSession to a state.
+func Classify(err error) State {
+	var retrieveErr *oauth2.RetrieveError
+	switch {
+	case err == nil:
+		return StateOK
+	case errors.Is(err, auth.ErrConsentRequired), errors.Is(err, auth.ErrSessionInvalid):
+		return StateNeedsConsent
+	case errors.As(err, &retrieveErr):
+		if retrieveErr.ErrorCode == "invalid_grant" ||
+			(retrieveErr.Response != nil && retrieveErr.Response.StatusCode == http.StatusUnauthorized) {
+			return StateNeedsLogin
+		}
+	}
+	return StateError
+}
+
+// SaveToken saves the token after EnsureSession returned err, with the
+// account locked. A transient error can leave the game session half updated,
+// so then only a refreshed OAuth token is kept since the old refresh token
+// stops working once it rotated.
+func SaveToken(account config.Account, token auth.JagexAccountAuth, err error) error {
+	if Classify(err) != StateError {
+		return account.SaveToken(&token)
+	}
+	saved, loadErr := account.Token()
+	if loadErr != nil {
+		return loadErr
+	}
+	if saved.Token.AccessToken == token.Token.AccessToken && saved.Token.RefreshToken == token.Token.RefreshToken {
+		return nil
+	}
+	saved.Token, saved.IDToken = token.Token, token.IDToken
+	return account.SaveToken(&saved)
+}
+
+// Lock serializes changes to an account's token, within this process and
+// with other launcher processes through config.Account.Lock. It returns the
+// unlock function.
+func (k *Keeper) Lock(ctx context.Context, account config.Account) (func(), error) {
+	k.mu.Lock()
+	l, ok := k.locks[account.Name()]
+	if !ok {
+		l = &sync.Mutex{}
+		k.locks[account.Name()] = l
+	}
+	k.mu.Unlock()
+
+	l.Lock()
+	unlockFile, err := account.Lock(ctx)
+	if err != nil {
+		l.Unlock()
+		return nil, fmt.Errorf("locking account %q: %w", account.Name(), err)
+	}
+	return func() {
+		unlockFile()
+		l.Unlock()
+	}, nil
+}
+
+func mustJSON(v any) []byte {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
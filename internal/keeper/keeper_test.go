@@ -0,0 +1,89 @@
+package keeper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"golang.org/x/oauth2"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want State
+	}{
+		{"ok", nil, StateOK},
+		{"consent", fmt.Errorf("wrapped: %w", auth.ErrConsentRequired), StateNeedsConsent},
+		{"session", auth.ErrSessionInvalid, StateNeedsConsent},
+		{"invalid grant", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}, StateNeedsLogin},
+		{"unauthorized", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusUnauthorized}}, StateNeedsLogin},
+		{"server error", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadGateway}}, StateError},
+		{"network", errors.New("connection refused"), StateError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveToken(t *testing.T) {
+	saved := auth.JagexAccountAuth{
+		Token:       oauth2.Token{AccessToken: "access", RefreshToken: "refresh"},
+		IDToken:     "id",
+		GameIDToken: "game",
+		Session:     "session",
+	}
+	refreshed := saved
+	refreshed.Token = oauth2.Token{AccessToken: "access2", RefreshToken: "refresh2"}
+	refreshed.IDToken = "id2"
+	refreshed.GameIDToken, refreshed.Session = "", ""
+
+	unchanged := saved
+	unchanged.GameIDToken, unchanged.Session = "", ""
+
+	tests := []struct {
+		name  string
+		token auth.JagexAccountAuth
+		err   error
+		want  auth.JagexAccountAuth
+	}{
+		{"ok", refreshed, nil, refreshed},
+		{"needs consent", refreshed, auth.ErrConsentRequired, refreshed},
+		{"transient keeps the session", unchanged, errors.New("timeout"), saved},
+		{"transient keeps a rotated token", refreshed, errors.New("timeout"), auth.JagexAccountAuth{
+			Token:       refreshed.Token,
+			IDToken:     "id2",
+			GameIDToken: "game",
+			Session:     "session",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := config.Account(t.TempDir())
+			err := account.SaveToken(&saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = SaveToken(account, tt.token, tt.err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := account.Token()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Token.RefreshToken != tt.want.Token.RefreshToken || got.IDToken != tt.want.IDToken ||
+				got.GameIDToken != tt.want.GameIDToken || got.Session != tt.want.Session {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
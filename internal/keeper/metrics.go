@@ -0,0 +1,50 @@
+package keeper
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	tokenExpiryDesc = prometheus.NewDesc(
+		"osrs_launcher_token_expiry_seconds",
+		"Seconds until the account's access token expires, negative once it has.",
+		[]string{"account"}, nil,
+	)
+	needsReauthDesc = prometheus.NewDesc(
+		"osrs_launcher_accounts_needing_reauth",
+		"Accounts that need a human to log in or grant consent again.",
+		nil, nil,
+	)
+	accountStateDesc = prometheus.NewDesc(
+		"osrs_launcher_account_state",
+		"State of the account's most recent check, 1 for the current state.",
+		[]string{"account", "state"}, nil,
+	)
+)
+
+// Describe implements prometheus.Collector.
+func (k *Keeper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tokenExpiryDesc
+	ch <- needsReauthDesc
+	ch <- accountStateDesc
+}
+
+// Collect implements prometheus.Collector with the gauges computed from the
+// latest status, so accounts that are removed disappear from the metrics.
+func (k *Keeper) Collect(ch chan<- prometheus.Metric) {
+	status := k.Status()
+	needsReauth := 0
+	for name, acct := range status.Accounts {
+		if acct.State.NeedsHuman() {
+			needsReauth++
+		}
+		ch <- prometheus.MustNewConstMetric(accountStateDesc, prometheus.GaugeValue, 1, name, string(acct.State))
+		if !acct.TokenExpiry.IsZero() {
+			ch <- prometheus.MustNewConstMetric(tokenExpiryDesc, prometheus.GaugeValue,
+				time.Until(acct.TokenExpiry).Seconds(), name)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(needsReauthDesc, prometheus.GaugeValue, float64(needsReauth))
+}
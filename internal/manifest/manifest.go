@@ -0,0 +1,117 @@
+// Package manifest reads the accounts manifest used to onboard many accounts
+// in one go, and the checkpoint that lets an interrupted import resume.
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/totp"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists accounts to log in to:
+//
+//	accounts:
+//	  - label: main
+//	    email: me@example.com
+//	    proxy: socks5://127.0.0.1:1080
+//	    group: bankstanders
+//	    character: Zezima
+//	    totp: JBSWY3DPEHPK3PXP
+type Manifest struct {
+	Accounts []Entry `yaml:"accounts"`
+}
+
+// Entry is an account of the manifest. Only the label is required, it
+// identifies the entry in the checkpoint.
+type Entry struct {
+	Label     string `yaml:"label"`
+	Email     string `yaml:"email"`
+	Proxy     string `yaml:"proxy"`
+	Group     string `yaml:"group"`
+	Character string `yaml:"character"`
+	// TOTP is an authenticator seed, a base32 secret or otpauth:// URI.
+	TOTP string `yaml:"totp"`
+}
+
+// Load reads and validates a manifest.
+func Load(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var m Manifest
+	err = dec.Decode(&m)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i, entry := range m.Accounts {
+		if entry.Label == "" {
+			return Manifest{}, fmt.Errorf("account %d has no label", i+1)
+		}
+		if seen[entry.Label] {
+			return Manifest{}, fmt.Errorf("label %q is used twice", entry.Label)
+		}
+		seen[entry.Label] = true
+		if entry.TOTP != "" {
+			if _, err := totp.Parse(entry.TOTP); err != nil {
+				return Manifest{}, fmt.Errorf("account %q: totp: %w", entry.Label, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Result is how importing an entry went.
+type Result struct {
+	// Account is the saved account the entry became.
+	Account   string    `json:"account,omitempty"`
+	Character string    `json:"character,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Done reports whether the entry does not need to be imported again.
+func (r Result) Done() bool {
+	return r.Account != "" && r.Error == ""
+}
+
+// Checkpoint records the result of every imported entry by label. It is
+// saved after each entry.
+type Checkpoint struct {
+	file    config.File
+	Results map[string]Result `json:"results"`
+}
+
+// CheckpointPath is where the checkpoint of a manifest is kept.
+func CheckpointPath(manifestPath string) string {
+	return manifestPath + ".checkpoint.json"
+}
+
+// LoadCheckpoint reads the checkpoint, an empty one if there is none yet.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{file: config.File(path), Results: make(map[string]Result)}
+	err := cp.file.ReadJSON(cp)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	if cp.Results == nil {
+		cp.Results = make(map[string]Result)
+	}
+	return cp, nil
+}
+
+// Record saves the result of an entry.
+func (c *Checkpoint) Record(label string, result Result) error {
+	result.At = time.Now()
+	c.Results[label] = result
+	return c.file.WriteJSON(c)
+}
@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []Entry
+		err  string
+	}{
+		{
+			name: "full",
+			yaml: `accounts:
+  - label: main
+    email: me@example.com
+    proxy: socks5://127.0.0.1:1080
+    group: bankstanders
+    character: Zezima
+    totp: JBSWY3DPEHPK3PXP
+  - label: alt
+`,
+			want: []Entry{
+				{Label: "main", Email: "me@example.com", Proxy: "socks5://127.0.0.1:1080", Group: "bankstanders", Character: "Zezima", TOTP: "JBSWY3DPEHPK3PXP"},
+				{Label: "alt"},
+			},
+		},
+		{name: "empty", yaml: "accounts: []\n"},
+		{name: "no label", yaml: "accounts:\n  - email: me@example.com\n", err: "account 1 has no label"},
+		{name: "duplicate label", yaml: "accounts:\n  - label: a\n  - label: a\n", err: `label "a" is used twice`},
+		{name: "unknown field", yaml: "accounts:\n  - label: a\n    password: hunter2\n", err: "field password not found"},
+		{name: "bad totp", yaml: "accounts:\n  - label: a\n    totp: not!base32\n", err: `account "a": totp`},
+		{name: "not yaml", yaml: "accounts: [", err: "parsing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "accounts.yaml")
+			err := os.WriteFile(path, []byte(tt.yaml), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := Load(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Accounts) != len(tt.want) {
+				t.Fatalf("got %d accounts, want %d", len(m.Accounts), len(tt.want))
+			}
+			for i := range tt.want {
+				if m.Accounts[i] != tt.want[i] {
+					t.Errorf("account %d: got %+v, want %+v", i, m.Accounts[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := CheckpointPath(filepath.Join(t.TempDir(), "accounts.yaml"))
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Results) != 0 {
+		t.Fatalf("new checkpoint has results: %v", cp.Results)
+	}
+
+	err = cp.Record("main", Result{Account: "Zezima"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cp.Record("alt", Result{Error: "login failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err = LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Results["main"].Done() {
+		t.Error("main is not done")
+	}
+	if cp.Results["alt"].Done() {
+		t.Error("failed alt is done")
+	}
+	if cp.Results["main"].At.IsZero() {
+		t.Error("result has no time")
+	}
+}
@@ -0,0 +1,88 @@
+// Package metrics holds the Prometheus metrics of the launcher. The auth
+// package records every call to Jagex here, the session keeper adds the
+// per account gauges when it runs.
+package metrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "osrs_launcher"
+
+// Registry holds every launcher metric plus the Go runtime and process ones.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// TokenRefreshes counts OAuth token refresh requests by result:
+	// "refreshed", "unchanged" when Jagex returned the same access token, or
+	// "failed". Tokens that are still valid are not refreshed or counted.
+	TokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "OAuth token refresh attempts by result.",
+	}, []string{"result"})
+
+	// RequestDuration is the latency of requests to Jagex by endpoint and
+	// result, the HTTP status code or "error" when there was no response.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "jagex_request_duration_seconds",
+		Help:      "Latency of requests to Jagex endpoints.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "result"})
+
+	// ProxyErrors counts requests that failed because of the proxy rather
+	// than Jagex.
+	ProxyErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "proxy_errors_total",
+		Help:      "Requests that failed to go through the configured proxy.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		TokenRefreshes,
+		RequestDuration,
+		ProxyErrors,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a request to a Jagex endpoint that started at
+// start. resp may be nil when err is set.
+func ObserveRequest(endpoint string, start time.Time, resp *http.Response, err error) {
+	result := "error"
+	if resp != nil {
+		result = strconv.Itoa(resp.StatusCode)
+	}
+	RequestDuration.WithLabelValues(endpoint, result).Observe(time.Since(start).Seconds())
+	if err != nil && IsProxyError(err) {
+		ProxyErrors.Inc()
+	}
+}
+
+// IsProxyError reports whether err came from connecting through a proxy.
+func IsProxyError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return true
+	}
+	// SOCKS dial errors are not net.OpErrors.
+	return strings.Contains(err.Error(), "socks connect")
+}
@@ -0,0 +1,265 @@
+// Package output renders game session credentials for the different clients
+// that can consume them.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/runelite"
+)
+
+// Stdout is the destination that writes to standard out instead of a file.
+const Stdout = "-"
+
+// Credentials are everything a client needs to log a character in.
+type Credentials struct {
+	Account     string `json:"account"`
+	CharacterID string `json:"character_id"`
+	SessionID   string `json:"session_id"`
+	DisplayName string `json:"display_name"`
+}
+
+// Env returns the credentials as the JX_* environment variables the Jagex
+// launcher passes to game clients.
+func (c Credentials) Env() map[string]string {
+	return map[string]string{
+		"JX_CHARACTER_ID":  c.CharacterID,
+		"JX_SESSION_ID":    c.SessionID,
+		"JX_DISPLAY_NAME":  c.DisplayName,
+		"JX_REFRESH_TOKEN": "",
+		"JX_ACCESS_TOKEN":  "",
+	}
+}
+
+// Environ returns Env as sorted "KEY=value" pairs for exec.Cmd.
+func (c Credentials) Environ() []string {
+	env := c.Env()
+	out := make([]string, 0, len(env))
+	for _, k := range sortedKeys(env) {
+		out = append(out, k+"="+env[k])
+	}
+	return out
+}
+
+// Format turns credentials into the bytes of a destination file.
+type Format interface {
+	// Render returns the new contents for the destination. existing is the
+	// current contents, or nil if there are none, so formats can merge
+	// instead of clobbering.
+	Render(existing []byte, creds Credentials) ([]byte, error)
+}
+
+// FormatFunc adapts a function that ignores existing contents to a Format.
+type FormatFunc func(creds Credentials) ([]byte, error)
+
+func (f FormatFunc) Render(_ []byte, creds Credentials) ([]byte, error) {
+	return f(creds)
+}
+
+// Formats are the built-in formats by name. "template" is handled by
+// ParseTarget because it needs an argument.
+var Formats = map[string]Format{
+	"runelite": RuneLite{},
+	"env":      FormatFunc(shellEnv),
+	"dotenv":   FormatFunc(dotEnv),
+	"json":     FormatFunc(jsonCreds),
+}
+
+// FormatNames lists the names accepted by ParseTarget.
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats)+1)
+	for name := range Formats {
+		names = append(names, name)
+	}
+	names = append(names, "template")
+	sort.Strings(names)
+	return names
+}
+
+// RuneLite merges the credentials into a credentials.properties file.
+type RuneLite struct{}
+
+func (RuneLite) Render(existing []byte, creds Credentials) ([]byte, error) {
+	return runelite.RenderCredentials(existing, runelite.Credentials{
+		CharacterID: creds.CharacterID,
+		SessionID:   creds.SessionID,
+		DisplayName: creds.DisplayName,
+	})
+}
+
+// Template renders a user supplied text/template. The template is executed
+// with the Credentials as its data.
+type Template struct {
+	tmpl *template.Template
+}
+
+func NewTemplate(path string) (*Template, error) {
+	tmpl, err := template.New("").Option("missingkey=error").ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return &Template{tmpl: tmpl.Lookup(filepath.Base(path))}, nil
+}
+
+func (t *Template) Render(_ []byte, creds Credentials) ([]byte, error) {
+	var buf bytes.Buffer
+	err := t.tmpl.Execute(&buf, creds)
+	if err != nil {
+		return nil, fmt.Errorf("execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func shellEnv(creds Credentials) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(creds.Env()) {
+		// Single quotes disable every expansion, only ' itself needs care.
+		v := strings.ReplaceAll(creds.Env()[k], `'`, `'\''`)
+		_, _ = fmt.Fprintf(&buf, "export %s='%s'\n", k, v)
+	}
+	return buf.Bytes(), nil
+}
+
+func dotEnv(creds Credentials) ([]byte, error) {
+	var buf bytes.Buffer
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `$`, `\$`)
+	for _, k := range sortedKeys(creds.Env()) {
+		_, _ = fmt.Fprintf(&buf, "%s=\"%s\"\n", k, replacer.Replace(creds.Env()[k]))
+	}
+	return buf.Bytes(), nil
+}
+
+func jsonCreds(creds Credentials) ([]byte, error) {
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Target is a format paired with where to write it.
+type Target struct {
+	Name        string
+	Format      Format
+	Destination string
+}
+
+// ParseTarget parses "FORMAT[:ARG][=DESTINATION]", for example
+// "runelite=$HOME/.runelite/credentials.properties", "env" or
+// "template:launch.tmpl=/tmp/launch.sh". Environment variables in the
+// destination are expanded. A missing destination is filled in by
+// defaultDestination, which receives the format name.
+//
+// The destination follows the first '=' after the format name, or the last
+// one after an argument, so a template path containing '=' needs an explicit
+// destination.
+func ParseTarget(spec string, defaultDestination func(name string) string) (Target, error) {
+	spec = strings.TrimSpace(spec)
+	name, rest := spec, ""
+	if end := strings.IndexAny(spec, ":="); end >= 0 {
+		name, rest = spec[:end], spec[end:]
+	}
+	var arg, dest string
+	hasArg := strings.HasPrefix(rest, ":")
+	switch {
+	case hasArg:
+		arg = rest[1:]
+		if i := strings.LastIndex(arg, "="); i >= 0 {
+			arg, dest = arg[:i], arg[i+1:]
+		}
+	case rest != "":
+		dest = rest[1:]
+	}
+
+	var target Target
+	target.Name = name
+	switch {
+	case name == "template":
+		if arg == "" {
+			return Target{}, fmt.Errorf("template format needs a path, use 'template:<file>'")
+		}
+		tmpl, err := NewTemplate(os.ExpandEnv(arg))
+		if err != nil {
+			return Target{}, err
+		}
+		target.Format = tmpl
+	case hasArg:
+		return Target{}, fmt.Errorf("format %q does not take an argument", name)
+	default:
+		format, ok := Formats[name]
+		if !ok {
+			return Target{}, fmt.Errorf("unknown format %q, must be one of %s", name, strings.Join(FormatNames(), ", "))
+		}
+		target.Format = format
+	}
+
+	dest = strings.TrimSpace(dest)
+	if dest == "" {
+		dest = defaultDestination(name)
+	}
+	if dest == "" {
+		dest = Stdout
+	}
+	target.Destination = os.ExpandEnv(dest)
+	return target, nil
+}
+
+// Write renders the credentials and writes them to the destination. Files
+// are backed up and replaced atomically with 0600 permissions.
+func (t Target) Write(stdout io.Writer, creds Credentials) error {
+	if t.Destination == Stdout {
+		data, err := t.Format.Render(nil, creds)
+		if err != nil {
+			return fmt.Errorf("render %s: %w", t.Name, err)
+		}
+		_, err = stdout.Write(data)
+		return err
+	}
+
+	existing, err := os.ReadFile(t.Destination)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", t.Destination, err)
+	}
+	data, err := t.Format.Render(existing, creds)
+	if err != nil {
+		return fmt.Errorf("render %s: %w", t.Name, err)
+	}
+
+	file := config.File(t.Destination)
+	err = file.Backup()
+	if err != nil {
+		return fmt.Errorf("backup %s: %w", t.Destination, err)
+	}
+	return file.WriteAtomicMode(data, t.mode())
+}
+
+// mode keeps the permissions of an existing destination. New template
+// output may be a script, so only it is made executable.
+func (t Target) mode() os.FileMode {
+	info, err := os.Stat(t.Destination)
+	if err == nil {
+		return info.Mode().Perm()
+	}
+	if _, ok := t.Format.(*Template); ok {
+		return 0o700
+	}
+	return 0o600
+}
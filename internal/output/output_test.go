@@ -0,0 +1,200 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testCreds = Credentials{
+	Account:     "main",
+	CharacterID: "123",
+	SessionID:   "abc",
+	DisplayName: `O'Brien "$x"`,
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"env", "export JX_ACCESS_TOKEN=''\n" +
+			"export JX_CHARACTER_ID='123'\n" +
+			"export JX_DISPLAY_NAME='O'\\''Brien \"$x\"'\n" +
+			"export JX_REFRESH_TOKEN=''\n" +
+			"export JX_SESSION_ID='abc'\n"},
+		{"dotenv", "JX_ACCESS_TOKEN=\"\"\n" +
+			"JX_CHARACTER_ID=\"123\"\n" +
+			"JX_DISPLAY_NAME=\"O'Brien \\\"\\$x\\\"\"\n" +
+			"JX_REFRESH_TOKEN=\"\"\n" +
+			"JX_SESSION_ID=\"abc\"\n"},
+		{"json", "{\n" +
+			"  \"account\": \"main\",\n" +
+			"  \"character_id\": \"123\",\n" +
+			"  \"session_id\": \"abc\",\n" +
+			"  \"display_name\": \"O'Brien \\\"$x\\\"\"\n" +
+			"}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Formats[tt.name].Render(nil, testCreds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	got := strings.Join(testCreds.Environ(), "\n")
+	want := strings.Join([]string{
+		"JX_ACCESS_TOKEN=",
+		"JX_CHARACTER_ID=123",
+		`JX_DISPLAY_NAME=O'Brien "$x"`,
+		"JX_REFRESH_TOKEN=",
+		"JX_SESSION_ID=abc",
+	}, "\n")
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "a=b.tmpl")
+	err := os.WriteFile(tmpl, []byte("{{.SessionID}}"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OUTPUT_TEST_DIR", dir)
+
+	defaults := func(name string) string {
+		if name == "runelite" {
+			return "default.properties"
+		}
+		return ""
+	}
+	tests := []struct {
+		spec     string
+		name     string
+		dest     string
+		template bool
+		err      string
+	}{
+		{spec: "env", name: "env", dest: Stdout},
+		{spec: " json ", name: "json", dest: Stdout},
+		{spec: "runelite", name: "runelite", dest: "default.properties"},
+		{spec: "dotenv=$OUTPUT_TEST_DIR/.env", name: "dotenv", dest: dir + "/.env"},
+		{spec: "dotenv=/tmp/a=b", name: "dotenv", dest: "/tmp/a=b"},
+		{spec: "json=-", name: "json", dest: Stdout},
+		{spec: "template:" + tmpl + "=out.sh", name: "template", dest: "out.sh", template: true},
+		{spec: "template:$OUTPUT_TEST_DIR/a=b.tmpl=-", name: "template", dest: Stdout, template: true},
+		{spec: "template", err: "needs a path"},
+		{spec: "template:=out", err: "needs a path"},
+		{spec: "env:x", err: "does not take an argument"},
+		{spec: "yaml", err: "unknown format"},
+		{spec: "template:" + filepath.Join(dir, "missing.tmpl"), err: "parse template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			target, err := ParseTarget(tt.spec, defaults)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target.Name != tt.name || target.Destination != tt.dest {
+				t.Errorf("got %s=%s, want %s=%s", target.Name, target.Destination, tt.name, tt.dest)
+			}
+			if _, ok := target.Format.(*Template); ok != tt.template {
+				t.Errorf("got format %T", target.Format)
+			}
+		})
+	}
+}
+
+func TestTargetWrite(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "creds.json")
+	target, err := ParseTarget("json="+dest, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = target.Write(nil, testCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("got mode %v, want 0600", info.Mode().Perm())
+	}
+
+	var stdout strings.Builder
+	target.Destination = Stdout
+	err = target.Write(&stdout, testCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), `"session_id": "abc"`) {
+		t.Errorf("unexpected stdout %q", stdout.String())
+	}
+}
+
+func TestTargetWriteMode(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "launch.sh.tmpl")
+	err := os.WriteFile(tmpl, []byte("#!/bin/sh\nexec client {{ .SessionID }}\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		spec     string
+		existing os.FileMode
+		want     os.FileMode
+	}{
+		{name: "new template output", spec: "template:" + tmpl + "=" + filepath.Join(dir, "new.sh"), want: 0o700},
+		{name: "existing template output", spec: "template:" + tmpl + "=" + filepath.Join(dir, "old.sh"), existing: 0o750, want: 0o750},
+		{name: "existing other output", spec: "env=" + filepath.Join(dir, "creds.env"), existing: 0o640, want: 0o640},
+		{name: "new other output", spec: "env=" + filepath.Join(dir, "new.env"), want: 0o600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ParseTarget(tt.spec, func(string) string { return "" })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing != 0 {
+				err = os.WriteFile(target.Destination, nil, tt.existing)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = os.Chmod(target.Destination, tt.existing)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = target.Write(nil, testCreds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(target.Destination)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.want {
+				t.Errorf("got mode %v, want %v", info.Mode().Perm(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package prompt
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/internal/browser"
+	"github.com/rs/zerolog/log"
+	"github.com/skip2/go-qrcode"
+)
+
+// Browser opens shown URLs in the desktop's browser. When there is none, or
+// NoBrowser is set, it draws them as a QR code so they can be opened on a
+// phone instead.
+type Browser struct {
+	auth.Prompter
+	Out       io.Writer
+	NoBrowser bool
+}
+
+func (b Browser) ShowURL(title, url string) error {
+	err := b.Prompter.ShowURL(title, url)
+	if err != nil {
+		return err
+	}
+
+	if !b.NoBrowser && browser.Available() {
+		err = browser.Open(url)
+		if err == nil {
+			_, err = fmt.Fprintln(b.Out, "Opened in your browser.")
+			return err
+		}
+		log.Warn().Err(err).Msg("Cannot open the browser, scan the QR code or copy the url instead")
+	}
+
+	qr, err := qrcode.New(url, qrcode.Low)
+	if err != nil {
+		// Only urls too long for any QR code fail, the url is shown above.
+		log.Debug().Err(err).Msg("no QR code")
+		return nil
+	}
+	_, err = fmt.Fprint(b.Out, qr.ToSmallString(false))
+	return err
+}
+
+// ReadRedirectStatus implements StatusReader for the wrapped prompter.
+func (b Browser) ReadRedirectStatus(title string, status Status) (string, error) {
+	return ReadRedirectStatus(b.Prompter, b.Out, title, status)
+}
@@ -0,0 +1,172 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+)
+
+// Lines prompts with plain text, one answer per line. Choices are numbered
+// and can be answered with the number or the name.
+type Lines struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+var _ auth.Prompter = (*Lines)(nil)
+
+func NewLines(in io.Reader, out io.Writer) *Lines {
+	return &Lines{in: bufio.NewReader(in), out: out}
+}
+
+func (l *Lines) ShowURL(title, url string) error {
+	_, err := fmt.Fprintf(l.out, "%s, visit this url:\n%s\n", title, url)
+	return err
+}
+
+func (l *Lines) ReadRedirect(title string) (string, error) {
+	return l.ask(title + ": ")
+}
+
+func (l *Lines) SelectAccount(accounts []string, allowNew bool) (string, error) {
+	choices := accounts
+	if allowNew {
+		choices = append([]string{"New Account"}, accounts...)
+	}
+	idx, err := l.choose("Select Jagex account", choices)
+	if err != nil {
+		return "", err
+	}
+	if allowNew {
+		if idx == 0 {
+			return newAccount, nil
+		}
+		idx--
+	}
+	return accounts[idx], nil
+}
+
+func (l *Lines) SelectCharacter(chars []auth.JagexCharacter) (auth.JagexCharacter, error) {
+	names := make([]string, 0, len(chars))
+	for _, char := range chars {
+		names = append(names, char.DisplayName)
+	}
+	idx, err := l.choose("Select character", names)
+	if err != nil {
+		return auth.JagexCharacter{}, err
+	}
+	return chars[idx], nil
+}
+
+func (l *Lines) Confirm(question string) (bool, error) {
+	for {
+		answer, err := l.ask(question + " [y/N]: ")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "", "n", "no":
+			return false, nil
+		}
+		_, _ = fmt.Fprintln(l.out, "Answer y or n.")
+	}
+}
+
+// SelectMany takes the numbers or names of the picked options separated by
+// commas, an empty answer picks all of them.
+func (l *Lines) SelectMany(title string, options []string) ([]string, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("nothing to select from")
+	}
+	_, _ = fmt.Fprintln(l.out, title)
+	for i, option := range options {
+		_, _ = fmt.Fprintf(l.out, "  %d) %s\n", i+1, option)
+	}
+	for {
+		answer, err := l.ask("All, or numbers separated by commas: ")
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" {
+			return options, nil
+		}
+		picked, ok := pickMany(options, answer)
+		if ok {
+			return picked, nil
+		}
+		_, _ = fmt.Fprintf(l.out, "Enter numbers from 1 to %d.\n", len(options))
+	}
+}
+
+// pickMany resolves comma separated numbers or names to options, in their
+// original order.
+func pickMany(options []string, answer string) ([]string, bool) {
+	chosen := make([]bool, len(options))
+	for _, part := range strings.Split(answer, ",") {
+		part = strings.TrimSpace(part)
+		idx := -1
+		if n, err := strconv.Atoi(part); err == nil && n >= 1 && n <= len(options) {
+			idx = n - 1
+		}
+		for i, option := range options {
+			if idx < 0 && strings.EqualFold(option, part) {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			return nil, false
+		}
+		chosen[idx] = true
+	}
+	var picked []string
+	for i, option := range options {
+		if chosen[i] {
+			picked = append(picked, option)
+		}
+	}
+	return picked, true
+}
+
+func (l *Lines) choose(title string, choices []string) (int, error) {
+	if len(choices) == 0 {
+		return 0, fmt.Errorf("nothing to select from")
+	}
+	_, _ = fmt.Fprintln(l.out, title)
+	for i, choice := range choices {
+		_, _ = fmt.Fprintf(l.out, "  %d) %s\n", i+1, choice)
+	}
+	for {
+		answer, err := l.ask("> ")
+		if err != nil {
+			return 0, err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			return n - 1, nil
+		}
+		for i, choice := range choices {
+			if strings.EqualFold(choice, answer) {
+				return i, nil
+			}
+		}
+		_, _ = fmt.Fprintf(l.out, "Enter a number from 1 to %d.\n", len(choices))
+	}
+}
+
+func (l *Lines) ask(prompt string) (string, error) {
+	_, _ = fmt.Fprint(l.out, prompt)
+	line, err := l.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("input closed")
+		}
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,37 @@
+// Package prompt implements auth.Prompter for terminals, plain line based
+// input and scripts.
+package prompt
+
+import (
+	"io"
+	"os"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"golang.org/x/term"
+)
+
+// Auto returns a TTY prompter when in is a terminal and a line based one
+// otherwise, so piping input into the launcher works.
+func Auto(in io.Reader, out io.Writer) auth.Prompter {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return TTY{Out: out}
+	}
+	return NewLines(in, out)
+}
+
+// New returns the prompter for a mode: "auto", "tty" or "lines".
+func New(mode string, in io.Reader, out io.Writer) auth.Prompter {
+	switch mode {
+	case "tty":
+		return TTY{Out: out}
+	case "lines":
+		return NewLines(in, out)
+	default:
+		return Auto(in, out)
+	}
+}
+
+// Modes are the values New accepts.
+func Modes() []string {
+	return []string{"auto", "tty", "lines"}
+}
@@ -0,0 +1,213 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+)
+
+var testCharacters = []auth.JagexCharacter{
+	{AccountID: "1", DisplayName: "Zezima"},
+	{AccountID: "2", DisplayName: "Lynx Titan"},
+}
+
+func TestLinesSelectAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		allowNew bool
+		want     string
+		err      bool
+	}{
+		{name: "number", input: "2\n", want: "alt"},
+		{name: "name", input: "ALT\n", want: "alt"},
+		{name: "new", input: "1\n", allowNew: true, want: newAccount},
+		{name: "offset by new", input: "2\n", allowNew: true, want: "main"},
+		{name: "retry", input: "7\nnope\nmain\n", want: "main"},
+		{name: "no newline", input: "1", want: "main"},
+		{name: "closed", input: "", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			got, err := NewLines(strings.NewReader(tt.input), &out).SelectAccount([]string{"main", "alt"}, tt.allowNew)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesSelectCharacter(t *testing.T) {
+	var out strings.Builder
+	got, err := NewLines(strings.NewReader("lynx titan\n"), &out).SelectCharacter(testCharacters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccountID != "2" {
+		t.Errorf("got %+v", got)
+	}
+	if !strings.Contains(out.String(), "  1) Zezima\n  2) Lynx Titan\n") {
+		t.Errorf("choices not listed in %q", out.String())
+	}
+}
+
+func TestLinesConfirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"maybe\ny\n", true},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		got, err := NewLines(strings.NewReader(tt.input), &out).Confirm("Sure?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLinesSelectMany(t *testing.T) {
+	options := []string{"flatpak", "jar", "appimage"}
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		err   bool
+	}{
+		{name: "all", input: "\n", want: options},
+		{name: "numbers", input: "3, 1\n", want: []string{"flatpak", "appimage"}},
+		{name: "names", input: "JAR\n", want: []string{"jar"}},
+		{name: "retry", input: "4\n2\n", want: []string{"jar"}},
+		{name: "closed", input: "", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			got, err := NewLines(strings.NewReader(tt.input), &out).SelectMany("Pick", options)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesReadRedirect(t *testing.T) {
+	var out strings.Builder
+	l := NewLines(strings.NewReader("  jagex:code=abc \n"), &out)
+	err := l.ShowURL("Log in", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.ReadRedirect("Paste")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "jagex:code=abc" {
+		t.Errorf("got %q", got)
+	}
+	if out.String() != "Log in, visit this url:\nhttps://example.com\nPaste: " {
+		t.Errorf("got output %q", out.String())
+	}
+}
+
+func TestScript(t *testing.T) {
+	s := &Script{
+		Redirects:  []string{"jagex:code=abc"},
+		Accounts:   []string{"alt", newAccount, "missing"},
+		Characters: []string{"Zezima"},
+		Confirms:   []bool{true},
+		Selections: [][]string{{"jar"}, {"missing"}},
+	}
+	_ = s.ShowURL("Log in", "https://example.com")
+	if len(s.URLs) != 1 || s.URLs[0] != "https://example.com" {
+		t.Errorf("got urls %v", s.URLs)
+	}
+
+	redirect, err := s.ReadRedirect("Paste")
+	if err != nil || redirect != "jagex:code=abc" {
+		t.Errorf("got %q, %v", redirect, err)
+	}
+	_, err = s.ReadRedirect("Paste")
+	if err == nil {
+		t.Error("answered with no redirects left")
+	}
+
+	accounts := []string{"main", "alt"}
+	account, err := s.SelectAccount(accounts, false)
+	if err != nil || account != "alt" {
+		t.Errorf("got %q, %v", account, err)
+	}
+	_, err = s.SelectAccount(accounts, false)
+	if err == nil {
+		t.Error("chose a new account when not allowed")
+	}
+	_, err = s.SelectAccount(accounts, true)
+	if err == nil {
+		t.Error("chose an account that is not listed")
+	}
+
+	char, err := s.SelectCharacter(testCharacters)
+	if err != nil || char.AccountID != "1" {
+		t.Errorf("got %+v, %v", char, err)
+	}
+	ok, err := s.Confirm("Sure?")
+	if err != nil || !ok {
+		t.Errorf("got %v, %v", ok, err)
+	}
+	_, err = s.Confirm("Sure?")
+	if err == nil {
+		t.Error("confirmed with no answers left")
+	}
+
+	options := []string{"flatpak", "jar"}
+	picked, err := s.SelectMany("Pick", options)
+	if err != nil || len(picked) != 1 || picked[0] != "jar" {
+		t.Errorf("got %q, %v", picked, err)
+	}
+	_, err = s.SelectMany("Pick", options)
+	if err == nil {
+		t.Error("picked an option that is not listed")
+	}
+}
+
+func TestReadRedirectStatus(t *testing.T) {
+	var out strings.Builder
+	s := &Script{Redirects: []string{"jagex:code=abc"}}
+	status := func(now time.Time) string { return "code 123456" }
+	got, err := ReadRedirectStatus(Browser{Prompter: s, Out: &out, NoBrowser: true}, &out, "Paste", status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "jagex:code=abc" || out.String() != "code 123456\n" {
+		t.Errorf("got %q with output %q", got, out.String())
+	}
+}
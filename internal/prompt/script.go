@@ -0,0 +1,108 @@
+package prompt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+)
+
+// Script answers prompts from fixed lists, for tests and automation. Each
+// prompt takes the next answer of its kind and fails once they run out.
+type Script struct {
+	// Redirects answer ReadRedirect.
+	Redirects []string
+	// Accounts answer SelectAccount with an account name, "" for a new
+	// account.
+	Accounts []string
+	// Characters answer SelectCharacter with a display name or id.
+	Characters []string
+	// Confirms answer Confirm.
+	Confirms []bool
+	// Selections answer SelectMany with the picked options.
+	Selections [][]string
+
+	mu sync.Mutex
+	// URLs records every URL shown.
+	URLs []string
+}
+
+var _ auth.Prompter = (*Script)(nil)
+
+func (s *Script) ShowURL(_, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.URLs = append(s.URLs, url)
+	return nil
+}
+
+func (s *Script) ReadRedirect(title string) (string, error) {
+	return pop(&s.mu, &s.Redirects, title)
+}
+
+func (s *Script) SelectAccount(accounts []string, allowNew bool) (string, error) {
+	name, err := pop(&s.mu, &s.Accounts, "account")
+	if err != nil {
+		return "", err
+	}
+	if name == newAccount {
+		if !allowNew {
+			return "", fmt.Errorf("script: new account not allowed")
+		}
+		return newAccount, nil
+	}
+	for _, account := range accounts {
+		if account == name {
+			return account, nil
+		}
+	}
+	return "", fmt.Errorf("script: no account %q among %s", name, strings.Join(accounts, ", "))
+}
+
+func (s *Script) SelectCharacter(chars []auth.JagexCharacter) (auth.JagexCharacter, error) {
+	query, err := pop(&s.mu, &s.Characters, "character")
+	if err != nil {
+		return auth.JagexCharacter{}, err
+	}
+	return auth.FindCharacter(chars, query)
+}
+
+func (s *Script) Confirm(question string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Confirms) == 0 {
+		return false, fmt.Errorf("script: no answer left for %q", question)
+	}
+	ok := s.Confirms[0]
+	s.Confirms = s.Confirms[1:]
+	return ok, nil
+}
+
+func (s *Script) SelectMany(title string, options []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Selections) == 0 {
+		return nil, fmt.Errorf("script: no answer left for %q", title)
+	}
+	picked := s.Selections[0]
+	s.Selections = s.Selections[1:]
+	for _, p := range picked {
+		if !slices.Contains(options, p) {
+			return nil, fmt.Errorf("script: no option %q among %s", p, strings.Join(options, ", "))
+		}
+	}
+	return picked, nil
+}
+
+func pop(mu *sync.Mutex, answers *[]string, what string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(*answers) == 0 {
+		return "", fmt.Errorf("script: no answer left for %q", what)
+	}
+	answer := (*answers)[0]
+	*answers = (*answers)[1:]
+	return answer, nil
+}
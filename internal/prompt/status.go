@@ -0,0 +1,66 @@
+package prompt
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+// Status is a line that changes over time, shown while waiting for input,
+// like an authenticator code and how long it stays valid.
+type Status func(now time.Time) string
+
+// StatusReader is a prompter that keeps a status up to date while it reads
+// the redirect.
+type StatusReader interface {
+	ReadRedirectStatus(title string, status Status) (string, error)
+}
+
+// ReadRedirectStatus reads the redirect with p, with a live status when p is
+// a StatusReader. Other prompters get the status written to out once.
+func ReadRedirectStatus(p auth.Prompter, out io.Writer, title string, status Status) (string, error) {
+	if sr, ok := p.(StatusReader); ok {
+		return sr.ReadRedirectStatus(title, status)
+	}
+	_, err := fmt.Fprintln(out, status(time.Now()))
+	if err != nil {
+		return "", err
+	}
+	return p.ReadRedirect(title)
+}
+
+// statusForm runs a form with one input whose description is redrawn every
+// second.
+type statusForm struct {
+	form   *huh.Form
+	input  *huh.Input
+	status Status
+}
+
+type statusTick time.Time
+
+func tickStatus() tea.Cmd {
+	return tea.Every(time.Second, func(t time.Time) tea.Msg { return statusTick(t) })
+}
+
+func (m statusForm) Init() tea.Cmd {
+	return tea.Batch(m.form.Init(), tickStatus())
+}
+
+func (m statusForm) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if t, ok := msg.(statusTick); ok {
+		m.input.Description(m.status(time.Time(t)))
+		return m, tickStatus()
+	}
+	form, cmd := m.form.Update(msg)
+	m.form = form.(*huh.Form)
+	return m, cmd
+}
+
+func (m statusForm) View() string {
+	return m.form.View()
+}
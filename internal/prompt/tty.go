@@ -0,0 +1,131 @@
+package prompt
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+// TTY prompts with huh forms.
+type TTY struct {
+	// Out is where URLs are printed and live forms are drawn.
+	Out io.Writer
+}
+
+var (
+	_ auth.Prompter = TTY{}
+	_ StatusReader  = TTY{}
+)
+
+// newAccount is the option value of logging in to a new account. Account
+// names are display names, which cannot be empty.
+const newAccount = ""
+
+func (t TTY) ShowURL(title, url string) error {
+	_, err := fmt.Fprintf(t.Out, "%s, visit this url:\n%s\n", title, url)
+	return err
+}
+
+func (t TTY) ReadRedirect(title string) (string, error) {
+	var redirect string
+	err := huh.NewInput().
+		Title(title).
+		Value(&redirect).
+		Run()
+	return redirect, err
+}
+
+// ReadRedirectStatus implements StatusReader, the status is shown under the
+// title.
+func (t TTY) ReadRedirectStatus(title string, status Status) (string, error) {
+	var redirect string
+	input := huh.NewInput().
+		Title(title).
+		Description(status(time.Now())).
+		Value(&redirect)
+	form := huh.NewForm(huh.NewGroup(input))
+	form.SubmitCmd = tea.Quit
+	form.CancelCmd = tea.Quit
+
+	m, err := tea.NewProgram(statusForm{form: form, input: input, status: status}, tea.WithOutput(t.Out)).Run()
+	if err != nil {
+		return "", err
+	}
+	if m.(statusForm).form.State == huh.StateAborted {
+		return "", huh.ErrUserAborted
+	}
+	return redirect, nil
+}
+
+func (t TTY) SelectAccount(accounts []string, allowNew bool) (string, error) {
+	options := make([]huh.Option[string], 0, len(accounts)+1)
+	if allowNew {
+		options = append(options, huh.NewOption("New Account", newAccount))
+	}
+	for _, account := range accounts {
+		options = append(options, huh.NewOption(account, account))
+	}
+	if len(options) == 0 {
+		return "", fmt.Errorf("no accounts to select from")
+	}
+
+	var sel string
+	err := huh.NewSelect[string]().
+		Title("Select Jagex account").
+		Options(options...).
+		Value(&sel).
+		Run()
+	return sel, err
+}
+
+func (t TTY) SelectCharacter(chars []auth.JagexCharacter) (auth.JagexCharacter, error) {
+	if len(chars) == 0 {
+		return auth.JagexCharacter{}, fmt.Errorf("account has no characters")
+	}
+	opts := make([]huh.Option[int], 0, len(chars))
+	for idx, char := range chars {
+		opts = append(opts, huh.NewOption(char.DisplayName, idx))
+	}
+
+	var idx int
+	err := huh.NewSelect[int]().
+		Title("Select character").
+		Options(opts...).
+		Value(&idx).
+		Run()
+	if err != nil {
+		return auth.JagexCharacter{}, err
+	}
+	return chars[idx], nil
+}
+
+func (t TTY) SelectMany(title string, options []string) ([]string, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("nothing to select from")
+	}
+	opts := make([]huh.Option[string], 0, len(options))
+	for _, option := range options {
+		opts = append(opts, huh.NewOption(option, option).Selected(true))
+	}
+
+	var sel []string
+	err := huh.NewMultiSelect[string]().
+		Title(title).
+		Options(opts...).
+		Value(&sel).
+		Run()
+	return sel, err
+}
+
+func (t TTY) Confirm(question string) (bool, error) {
+	var ok bool
+	err := huh.NewConfirm().
+		Title(question).
+		Value(&ok).
+		Run()
+	return ok, err
+}
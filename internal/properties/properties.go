@@ -0,0 +1,363 @@
+// Package properties reads and writes Java .properties files.
+//
+// The format follows java.util.Properties#load and #store. Unlike a plain
+// map, a parsed file remembers its comments, blank lines and the original
+// spelling of entries it did not touch, so rewriting a file only changes the
+// keys that were set or deleted.
+package properties
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// line is a single logical line of a properties file. Entries that have not
+// been modified keep their raw text so they are written back byte for byte.
+type line struct {
+	raw   string
+	entry bool
+	key   string
+	value string
+}
+
+type Properties struct {
+	lines []line
+}
+
+// New returns an empty set of properties.
+func New() *Properties {
+	return &Properties{}
+}
+
+// Parse reads a properties file. The input is decoded as UTF-8 when valid,
+// otherwise as ISO-8859-1 which is what Java uses for byte streams.
+func Parse(r io.Reader) (*Properties, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	if !utf8.Valid(data) {
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+
+	p := New()
+	var (
+		raw     []string
+		logical strings.Builder
+	)
+	for _, physical := range physicalLines(text) {
+		if len(raw) == 0 {
+			trimmed := strings.TrimLeft(physical, " \t\f")
+			if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!' {
+				p.lines = append(p.lines, line{raw: physical})
+				continue
+			}
+			logical.WriteString(trimmed)
+		} else {
+			// Leading whitespace on continuation lines is ignored.
+			logical.WriteString(strings.TrimLeft(physical, " \t\f"))
+		}
+		raw = append(raw, physical)
+
+		if continues(logical.String()) {
+			s := logical.String()
+			logical.Reset()
+			logical.WriteString(s[:len(s)-1])
+			continue
+		}
+
+		key, value, err := parseEntry(logical.String())
+		if err != nil {
+			return nil, fmt.Errorf("line %q: %w", raw[0], err)
+		}
+		p.lines = append(p.lines, line{
+			raw:   strings.Join(raw, "\n"),
+			entry: true,
+			key:   key,
+			value: value,
+		})
+		raw = raw[:0]
+		logical.Reset()
+	}
+	if len(raw) > 0 {
+		// A trailing backslash on the last line continues into nothing.
+		key, value, err := parseEntry(logical.String())
+		if err != nil {
+			return nil, fmt.Errorf("line %q: %w", raw[0], err)
+		}
+		p.lines = append(p.lines, line{raw: strings.Join(raw, "\n"), entry: true, key: key, value: value})
+	}
+	return p, nil
+}
+
+// physicalLines splits text at "\n", "\r" or "\r\n", the line terminators
+// Java accepts.
+func physicalLines(text string) []string {
+	var lines []string
+	for text != "" {
+		end := strings.IndexAny(text, "\r\n")
+		if end < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:end])
+		if strings.HasPrefix(text[end:], "\r\n") {
+			end++
+		}
+		text = text[end+1:]
+	}
+	return lines
+}
+
+// continues reports whether the line ends in an odd number of backslashes,
+// which joins it with the next line.
+func continues(s string) bool {
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func parseEntry(s string) (string, string, error) {
+	// The key ends at the first unescaped '=', ':' or whitespace.
+	end := len(s)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			end = i
+			break
+		}
+	}
+	rawKey := s[:end]
+	rest := strings.TrimLeft(s[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	key, err := unescape(rawKey)
+	if err != nil {
+		return "", "", fmt.Errorf("key: %w", err)
+	}
+	value, err := unescape(rest)
+	if err != nil {
+		return "", "", fmt.Errorf("value: %w", err)
+	}
+	return key, value, nil
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i == len(s)-1 {
+			// Java drops a backslash that escapes nothing.
+			break
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(s) {
+				return "", fmt.Errorf("malformed \\uxxxx encoding")
+			}
+			n, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uxxxx encoding: %w", err)
+			}
+			r := rune(n)
+			// Characters outside the BMP are written as surrogate pairs.
+			if r >= 0xD800 && r < 0xDC00 && i+10 < len(s) && s[i+5] == '\\' && s[i+6] == 'u' {
+				if lo, err := strconv.ParseUint(s[i+7:i+11], 16, 16); err == nil && lo >= 0xDC00 && lo < 0xE000 {
+					r = (r-0xD800)<<10 + (rune(lo) - 0xDC00) + 0x10000
+					i += 6
+				}
+			}
+			b.WriteRune(r)
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// Get returns the value of key. When a key appears more than once the last
+// occurrence wins, matching Java.
+func (p *Properties) Get(key string) (string, bool) {
+	for i := len(p.lines) - 1; i >= 0; i-- {
+		if p.lines[i].entry && p.lines[i].key == key {
+			return p.lines[i].value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value of key in place, or appends it if it is new.
+// Duplicate earlier occurrences of the key are removed.
+func (p *Properties) Set(key, value string) {
+	idx := -1
+	for i := len(p.lines) - 1; i >= 0; i-- {
+		if p.lines[i].entry && p.lines[i].key == key {
+			idx = i
+			break
+		}
+	}
+	l := line{entry: true, key: key, value: value}
+	l.raw = escapeKey(key) + "=" + escapeValue(value)
+	if idx < 0 {
+		p.lines = append(p.lines, l)
+		return
+	}
+	if p.lines[idx].value == value {
+		l.raw = p.lines[idx].raw
+	}
+	p.lines[idx] = l
+	p.deleteBefore(key, idx)
+}
+
+// Delete removes every occurrence of key.
+func (p *Properties) Delete(key string) {
+	p.deleteBefore(key, len(p.lines))
+}
+
+func (p *Properties) deleteBefore(key string, end int) {
+	kept := p.lines[:0]
+	for i, l := range p.lines {
+		if i < end && l.entry && l.key == key {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	p.lines = kept
+}
+
+// Keys returns the keys in file order without duplicates.
+func (p *Properties) Keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, l := range p.lines {
+		if l.entry && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// AddComment appends a comment line. Newlines in the text start new comment
+// lines.
+func (p *Properties) AddComment(text string) {
+	for _, s := range strings.Split(text, "\n") {
+		p.lines = append(p.lines, line{raw: "# " + escapeComment(s)})
+	}
+}
+
+// WriteTo writes the properties in a form that Java can load from a byte
+// stream: everything outside of printable ASCII is \u escaped.
+func (p *Properties) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, l := range p.lines {
+		buf.WriteString(l.raw)
+		buf.WriteByte('\n')
+	}
+	return buf.WriteTo(w)
+}
+
+// Bytes returns the encoded properties file.
+func (p *Properties) Bytes() []byte {
+	var buf bytes.Buffer
+	_, _ = p.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+func escapeKey(s string) string {
+	return escape(s, true)
+}
+
+func escapeValue(s string) string {
+	return escape(s, false)
+}
+
+func escape(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case ' ':
+			// Spaces are only significant at the start of a value, but every
+			// space in a key would terminate it.
+			if key || i == 0 {
+				b.WriteString(`\ `)
+			} else {
+				b.WriteByte(' ')
+			}
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			writeRune(&b, r)
+		}
+	}
+	return b.String()
+}
+
+func escapeComment(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\r' {
+			continue
+		}
+		writeRune(&b, r)
+	}
+	return b.String()
+}
+
+func writeRune(b *strings.Builder, r rune) {
+	if r >= 0x20 && r <= 0x7e {
+		b.WriteRune(r)
+		return
+	}
+	if r > 0xFFFF {
+		r -= 0x10000
+		fmt.Fprintf(b, `\u%04X\u%04X`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		return
+	}
+	fmt.Fprintf(b, `\u%04X`, r)
+}
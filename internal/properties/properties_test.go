@@ -0,0 +1,161 @@
+package properties
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{name: "Equals", input: "key=value", want: map[string]string{"key": "value"}},
+		{name: "Colon", input: "key:value", want: map[string]string{"key": "value"}},
+		{name: "Whitespace", input: "key value", want: map[string]string{"key": "value"}},
+		{name: "SpacedSeparator", input: "key  =  value", want: map[string]string{"key": "value"}},
+		{name: "WhitespaceThenColon", input: "key \t: value", want: map[string]string{"key": "value"}},
+		{name: "SecondSeparatorInValue", input: "key==value", want: map[string]string{"key": "=value"}},
+		{name: "NoValue", input: "key", want: map[string]string{"key": ""}},
+		{name: "TrailingSpaceKept", input: "key=value  ", want: map[string]string{"key": "value  "}},
+		{name: "LeadingWhitespace", input: "  \t key=value", want: map[string]string{"key": "value"}},
+		{name: "EscapedSeparators", input: `a\=b\:c\ d=e`, want: map[string]string{"a=b:c d": "e"}},
+		{name: "Escapes", input: `key=\t\n\r\f\\\q`, want: map[string]string{"key": "\t\n\r\f\\q"}},
+		{name: "Unicode", input: `key=café €`, want: map[string]string{"key": "café €"}},
+		{name: "SurrogatePair", input: `key=😀`, want: map[string]string{"key": "😀"}},
+		{name: "Continuation", input: "key=one \\\n    two\\\n three", want: map[string]string{"key": "one twothree"}},
+		{name: "EvenBackslashes", input: "a=b\\\\\nc=d", want: map[string]string{"a": `b\`, "c": "d"}},
+		{name: "ContinuationAtEOF", input: "key=value\\", want: map[string]string{"key": "value"}},
+		{name: "Comments", input: "# c=1\n! d=2\n  # e=3\nkey=value", want: map[string]string{"key": "value"}},
+		{name: "CRLF", input: "a=1\r\nb=2\r\n", want: map[string]string{"a": "1", "b": "2"}},
+		{name: "LoneCR", input: "a=1\rb=2\r", want: map[string]string{"a": "1", "b": "2"}},
+		{name: "ContinuationCR", input: "a=1\\\r  2", want: map[string]string{"a": "12"}},
+		{name: "LastWins", input: "a=1\na=2", want: map[string]string{"a": "2"}},
+		{name: "Latin1", input: "key=caf\xe9", want: map[string]string{"key": "café"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := len(p.Keys()); got != len(tt.want) {
+				t.Errorf("got keys %q, want %d keys", p.Keys(), len(tt.want))
+			}
+			for key, want := range tt.want {
+				got, ok := p.Get(key)
+				if !ok || got != want {
+					t.Errorf("Get(%q) = %q, %v, want %q", key, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{`key=\u12`, `key=\uZZZZ`, `\u00=value`} {
+		_, err := Parse(strings.NewReader(input))
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestUnescapeTrailingBackslash(t *testing.T) {
+	t.Parallel()
+
+	got, err := unescape(`value\`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "value" {
+		t.Errorf("got %q, want %q", got, "value")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	input := "# RuneLite credentials\n" +
+		"! other comment\n" +
+		"\n" +
+		"keep  :  spaced \\\n   continued\n" +
+		"unicode=caf\\u00e9\n" +
+		"JX_SESSION_ID=old\n"
+	p, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(p.Bytes()); got != input {
+		t.Fatalf("unchanged file was rewritten:\n%s\nwant:\n%s", got, input)
+	}
+
+	p.Set("keep", "spaced continued")
+	p.Set("JX_SESSION_ID", "new")
+	p.Set("JX_DISPLAY_NAME", "Zezima")
+	want := "# RuneLite credentials\n" +
+		"! other comment\n" +
+		"\n" +
+		"keep  :  spaced \\\n   continued\n" +
+		"unicode=caf\\u00e9\n" +
+		"JX_SESSION_ID=new\n" +
+		"JX_DISPLAY_NAME=Zezima\n"
+	if got := string(p.Bytes()); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSetEscapes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key, value string
+		want       string
+	}{
+		{key: "a", value: "b", want: "a=b\n"},
+		{key: "a b", value: " c d", want: `a\ b=\ c d` + "\n"},
+		{key: "k=:#!", value: "v=:#!", want: `k\=\:\#\!=v\=\:\#\!` + "\n"},
+		{key: "tabs", value: "\t\n\r\f\\", want: `tabs=\t\n\r\f\\` + "\n"},
+		{key: "uni", value: "café €😀", want: `uni=caf\u00E9 \u20AC\uD83D\uDE00` + "\n"},
+	}
+	for _, tt := range tests {
+		p := New()
+		p.Set(tt.key, tt.value)
+		got := string(p.Bytes())
+		if got != tt.want {
+			t.Errorf("Set(%q, %q) wrote %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+		// What is written reads back the same.
+		back, err := Parse(strings.NewReader(got))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := back.Get(tt.key); v != tt.value {
+			t.Errorf("read back %q, want %q", v, tt.value)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	p, err := Parse(strings.NewReader("# c\na=1\nb=2\na=3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Delete("a")
+	if got := string(p.Bytes()); got != "# c\nb=2\n" {
+		t.Errorf("got %q", got)
+	}
+	p.AddComment("one\ntwo")
+	if got := string(p.Bytes()); got != "# c\nb=2\n# one\n# two\n" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,216 @@
+// Package redirect parses the redirect a browser lands on after logging in to
+// a Jagex account and granting consent, in every form it gets pasted back to
+// the launcher.
+package redirect
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrEmpty is returned when nothing but whitespace was pasted.
+	ErrEmpty = errors.New("nothing was pasted")
+	// ErrUnknownForm is returned for input that is neither a jagex: link nor
+	// a launcher-redirect URL.
+	ErrUnknownForm = errors.New("expected a jagex:code=... link or a launcher-redirect URL")
+	// ErrNoCode is returned when the redirect has no code, like when the
+	// login was cancelled.
+	ErrNoCode = errors.New("the redirect has no code")
+	// ErrNotConsent is returned by ParseConsent for anything but a
+	// http://localhost url.
+	ErrNotConsent = errors.New("expected the http://localhost/#... url of the consent redirect")
+	// ErrNoIDToken is returned when the consent redirect has no id_token.
+	ErrNoIDToken = errors.New("the consent redirect has no id_token")
+)
+
+// Redirect is the result of a login.
+type Redirect struct {
+	Code   string
+	State  string
+	Intent string
+}
+
+// SyntaxError describes a malformed part of a redirect.
+type SyntaxError struct {
+	// Item is the offending part, without its value so codes do not end up
+	// in logs.
+	Item   string
+	Reason string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("malformed redirect at %q: %s", e.Item, e.Reason)
+}
+
+// LoginError is an OAuth error the login page redirected with instead of a
+// code.
+type LoginError struct {
+	Code        string
+	Description string
+}
+
+func (e *LoginError) Error() string {
+	if e.Description == "" {
+		return "login failed: " + e.Code
+	}
+	return fmt.Sprintf("login failed: %s: %s", e.Code, e.Description)
+}
+
+// Consent is the result of the consent page.
+type Consent struct {
+	IDToken string
+	Code    string
+	State   string
+}
+
+// ParseConsent accepts the url the consent page redirects to,
+// http://localhost/#id_token=...&code=...&state=..., also with the fragment
+// moved to the query the way the launcher's own callback page does it.
+func ParseConsent(s string) (Consent, error) {
+	s = stripSpace(s)
+	if s == "" {
+		return Consent{}, ErrEmpty
+	}
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "http%3a") {
+		unescaped, err := url.QueryUnescape(s)
+		if err != nil {
+			return Consent{}, &SyntaxError{Item: "url encoding", Reason: err.Error()}
+		}
+		s, lower = unescaped, strings.ToLower(unescaped)
+	}
+	if !strings.HasPrefix(lower, "http://localhost") {
+		return Consent{}, ErrNotConsent
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return Consent{}, &SyntaxError{Item: "url", Reason: err.Error()}
+	}
+	raw := u.Fragment
+	if raw == "" {
+		raw = u.RawQuery
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return Consent{}, &SyntaxError{Item: "fragment", Reason: err.Error()}
+	}
+	for _, key := range []string{"id_token", "code", "state"} {
+		if len(values[key]) > 1 {
+			return Consent{}, &SyntaxError{Item: key, Reason: "given more than once"}
+		}
+	}
+	if code := values.Get("error"); code != "" {
+		return Consent{}, &LoginError{Code: code, Description: values.Get("error_description")}
+	}
+	c := Consent{
+		IDToken: values.Get("id_token"),
+		Code:    values.Get("code"),
+		State:   values.Get("state"),
+	}
+	if c.IDToken == "" {
+		return Consent{}, ErrNoIDToken
+	}
+	return c, nil
+}
+
+// Parse accepts:
+//
+//	jagex:code=...,state=...,intent=social_auth
+//	https://secure.runescape.com/m=weblogin/launcher-redirect?code=...&state=...
+//
+// either of them URL-encoded, and with whitespace around or inside them from
+// wrapped terminal lines.
+func Parse(s string) (Redirect, error) {
+	s = stripSpace(s)
+	if s == "" {
+		return Redirect{}, ErrEmpty
+	}
+
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "jagex%3a") || strings.HasPrefix(lower, "https%3a") || strings.HasPrefix(lower, "http%3a") {
+		// Path unescaping keeps a literal + of a jagex: value, whitespace
+		// was stripped so there is no space it could stand for.
+		unescaped, err := url.PathUnescape(s)
+		if err != nil {
+			return Redirect{}, &SyntaxError{Item: "url encoding", Reason: err.Error()}
+		}
+		s, lower = unescaped, strings.ToLower(unescaped)
+	}
+
+	switch {
+	case strings.HasPrefix(lower, "jagex:"):
+		return parseJagex(s[len("jagex:"):])
+	case strings.HasPrefix(lower, "https://"), strings.HasPrefix(lower, "http://"):
+		return parseURL(s)
+	}
+	return Redirect{}, ErrUnknownForm
+}
+
+// stripSpace drops all whitespace, none is part of a redirect but terminals
+// add line breaks to long ones.
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// parseJagex reads the comma separated key=value pairs of a jagex: link.
+// Values may contain '=' and be URL-encoded.
+func parseJagex(s string) (Redirect, error) {
+	values := make(url.Values)
+	for _, item := range strings.Split(s, ",") {
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return Redirect{}, &SyntaxError{Item: item, Reason: "missing '='"}
+		}
+		// The jagex: form is not a query string, + is part of the value.
+		unescaped, err := url.PathUnescape(value)
+		if err != nil {
+			return Redirect{}, &SyntaxError{Item: key, Reason: "bad url encoding"}
+		}
+		values.Add(key, unescaped)
+	}
+	return fromValues(values)
+}
+
+func parseURL(s string) (Redirect, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Redirect{}, &SyntaxError{Item: "url", Reason: err.Error()}
+	}
+	values, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return Redirect{}, &SyntaxError{Item: "query", Reason: err.Error()}
+	}
+	return fromValues(values)
+}
+
+func fromValues(values url.Values) (Redirect, error) {
+	for _, key := range []string{"code", "state", "intent"} {
+		if len(values[key]) > 1 {
+			return Redirect{}, &SyntaxError{Item: key, Reason: "given more than once"}
+		}
+	}
+	if code := values.Get("error"); code != "" {
+		return Redirect{}, &LoginError{Code: code, Description: values.Get("error_description")}
+	}
+	r := Redirect{
+		Code:   values.Get("code"),
+		State:  values.Get("state"),
+		Intent: values.Get("intent"),
+	}
+	if r.Code == "" {
+		return Redirect{}, ErrNoCode
+	}
+	return r, nil
+}
@@ -0,0 +1,214 @@
+package redirect
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	want := Redirect{Code: "abc.d-e_f", State: "1234", Intent: "social_auth"}
+	tests := []struct {
+		name  string
+		input string
+		want  Redirect
+	}{
+		{name: "jagex", input: "jagex:code=abc.d-e_f,state=1234,intent=social_auth", want: want},
+		{name: "jagex upper case scheme", input: "JAGEX:code=abc.d-e_f,state=1234,intent=social_auth", want: want},
+		{name: "jagex any order", input: "jagex:intent=social_auth,state=1234,code=abc.d-e_f", want: want},
+		{name: "jagex trailing comma", input: "jagex:code=abc.d-e_f,state=1234,intent=social_auth,", want: want},
+		{name: "jagex encoded value", input: "jagex:code=abc%2Ed-e_f,state=1234,intent=social_auth", want: want},
+		{name: "jagex value with +", input: "jagex:code=a+b/c,state=12+34", want: Redirect{Code: "a+b/c", State: "12+34"}},
+		{name: "encoded jagex value with +", input: "jagex%3Acode%3Da%2Bb%2Cstate%3D12+34", want: Redirect{Code: "a+b", State: "12+34"}},
+		{name: "jagex value with =", input: "jagex:code=abc==,state=1234", want: Redirect{Code: "abc==", State: "1234"}},
+		{name: "https", input: "https://secure.runescape.com/m=weblogin/launcher-redirect?code=abc.d-e_f&state=1234&intent=social_auth", want: want},
+		{name: "http", input: "http://localhost/launcher-redirect?code=abc.d-e_f&state=1234&intent=social_auth", want: want},
+		{name: "encoded jagex", input: "jagex%3Acode%3Dabc.d-e_f%2Cstate%3D1234%2Cintent%3Dsocial_auth", want: want},
+		{name: "encoded https", input: "https%3A%2F%2Fsecure.runescape.com%2Fm%3Dweblogin%2Flauncher-redirect%3Fcode%3Dabc.d-e_f%26state%3D1234%26intent%3Dsocial_auth", want: want},
+		{name: "wrapped lines", input: "  jagex:code=abc.d-e\n_f,state=12\r\n34,intent=social_auth \t", want: want},
+		{name: "no state", input: "jagex:code=abc", want: Redirect{Code: "abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		is     error
+		syntax string
+		login  *LoginError
+	}{
+		{name: "empty", input: " \n\t", is: ErrEmpty},
+		{name: "unknown form", input: "code=abc", is: ErrUnknownForm},
+		{name: "other scheme", input: "ftp://example.com/?code=abc", is: ErrUnknownForm},
+		{name: "no code", input: "jagex:state=1234", is: ErrNoCode},
+		{name: "empty code", input: "https://example.com/?code=&state=1234", is: ErrNoCode},
+		{name: "cancelled", input: "https://example.com/", is: ErrNoCode},
+		{name: "missing =", input: "jagex:code=abc,state", syntax: "state"},
+		{name: "bad encoding", input: "jagex:code=abc%zz", syntax: "code"},
+		{name: "bad outer encoding", input: "jagex%3Acode%3D%zz", syntax: "url encoding"},
+		{name: "duplicate code", input: "jagex:code=a,code=b", syntax: "code"},
+		{name: "duplicate state", input: "https://example.com/?code=a&state=1&state=2", syntax: "state"},
+		{name: "bad query", input: "https://example.com/?code=a%zz", syntax: "query"},
+		{name: "bad url", input: "https://exa mple.com:port/?code=a", syntax: "url"},
+		{name: "login error", input: "jagex:error=access_denied,error_description=User%20cancelled",
+			login: &LoginError{Code: "access_denied", Description: "User cancelled"}},
+		{name: "login error without description", input: "https://example.com/?error=server_error&state=1",
+			login: &LoginError{Code: "server_error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("got %v, want %v", err, tt.is)
+			}
+			var syntaxErr *SyntaxError
+			if errors.As(err, &syntaxErr) != (tt.syntax != "") {
+				t.Fatalf("got %T %v, want a syntax error: %v", err, err, tt.syntax != "")
+			}
+			if tt.syntax != "" && syntaxErr.Item != tt.syntax {
+				t.Errorf("got item %q, want %q", syntaxErr.Item, tt.syntax)
+			}
+			var loginErr *LoginError
+			if errors.As(err, &loginErr) != (tt.login != nil) {
+				t.Fatalf("got %T %v, want a login error: %v", err, err, tt.login != nil)
+			}
+			if tt.login != nil && *loginErr != *tt.login {
+				t.Errorf("got %+v, want %+v", loginErr, tt.login)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorHidesValues(t *testing.T) {
+	_, err := Parse("jagex:code=secret%zz")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("got %v, the error must not contain the code", err)
+	}
+}
+
+func TestParseConsent(t *testing.T) {
+	want := Consent{IDToken: "eyJ.a.b", Code: "abc", State: "1234"}
+	tests := []struct {
+		name  string
+		input string
+		want  Consent
+		is    error
+		login bool
+	}{
+		{name: "fragment", input: "http://localhost/#id_token=eyJ.a.b&code=abc&state=1234", want: want},
+		{name: "query", input: "http://localhost/?id_token=eyJ.a.b&code=abc&state=1234", want: want},
+		{name: "port", input: "http://localhost:8080/#id_token=eyJ.a.b&code=abc&state=1234", want: want},
+		{name: "encoded", input: url.QueryEscape("http://localhost/#id_token=eyJ.a.b&code=abc&state=1234"), want: want},
+		{name: "wrapped lines", input: " http://localhost/#id_token=eyJ.a\n.b&code=abc&state=1234\n", want: want},
+		{name: "empty", input: "", is: ErrEmpty},
+		{name: "other host", input: "https://example.com/#id_token=x", is: ErrNotConsent},
+		{name: "launcher redirect", input: "jagex:code=abc", is: ErrNotConsent},
+		{name: "no id token", input: "http://localhost/#code=abc&state=1234", is: ErrNoIDToken},
+		{name: "login error", input: "http://localhost/#error=consent_required&state=1234", login: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConsent(tt.input)
+			var loginErr *LoginError
+			switch {
+			case tt.is != nil:
+				if !errors.Is(err, tt.is) {
+					t.Errorf("got %v, want %v", err, tt.is)
+				}
+			case tt.login:
+				if !errors.As(err, &loginErr) {
+					t.Errorf("got %v, want a login error", err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case got != tt.want:
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// equivalentForms are the ways the same redirect gets pasted.
+func equivalentForms(s string) []string {
+	return []string{
+		url.QueryEscape(s),
+		"  " + s + "\n",
+		"\t" + url.QueryEscape(s) + " \r\n",
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("jagex:code=abc,state=1234,intent=social_auth")
+	f.Add("https://secure.runescape.com/m=weblogin/launcher-redirect?code=abc&state=1234")
+	f.Add("jagex%3Acode%3Dabc")
+	f.Add("jagex:error=access_denied")
+	f.Add("jagex:code=a%2C,state")
+	f.Add("jagex:code=a+b%2Bc,state=1+2")
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := Parse(s)
+		if err != nil {
+			return
+		}
+		if got.Code == "" {
+			t.Fatalf("parsed %q without a code", s)
+		}
+		// Only unescaped https and jagex: links are pasted encoded as well.
+		lower := strings.ToLower(stripSpace(s))
+		if !strings.HasPrefix(lower, "jagex:") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "http://") {
+			return
+		}
+		for _, form := range equivalentForms(stripSpace(s)) {
+			other, err := Parse(form)
+			if err != nil {
+				t.Fatalf("%q parses but %q fails: %v", s, form, err)
+			}
+			if other != got {
+				t.Fatalf("%q parses to %+v but %q to %+v", s, got, form, other)
+			}
+		}
+	})
+}
+
+func FuzzParseConsent(f *testing.F) {
+	f.Add("http://localhost/#id_token=eyJ.a.b&code=abc&state=1234")
+	f.Add("http://localhost/?id_token=x")
+	f.Add("http%3A%2F%2Flocalhost%2F%23id_token%3Dx")
+	f.Add("http://localhost/#error=consent_required")
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := ParseConsent(s)
+		if err != nil {
+			return
+		}
+		if got.IDToken == "" {
+			t.Fatalf("parsed %q without an id_token", s)
+		}
+		if !strings.HasPrefix(strings.ToLower(stripSpace(s)), "http://") {
+			return
+		}
+		for _, form := range equivalentForms(stripSpace(s)) {
+			other, err := ParseConsent(form)
+			if err != nil {
+				t.Fatalf("%q parses but %q fails: %v", s, form, err)
+			}
+			if other != got {
+				t.Fatalf("%q parses to %+v but %q to %+v", s, got, form, other)
+			}
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package runelite
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/Emyrk/osrs-launcher/internal/properties"
+)
+
+// Credentials are the values RuneLite reads from credentials.properties to
+// skip the Jagex launcher.
+type Credentials struct {
+	CharacterID string
+	SessionID   string
+	DisplayName string
+}
+
+// RenderCredentials sets the JX_* keys in an existing credentials file. Any
+// other keys and comments already in the file are kept. existing may be nil
+// when there is no file yet.
+func RenderCredentials(existing []byte, creds Credentials) ([]byte, error) {
+	props := properties.New()
+	if existing != nil {
+		var err error
+		props, err = properties.Parse(bytes.NewReader(existing))
+		if err != nil {
+			return nil, fmt.Errorf("parse existing credentials: %w", err)
+		}
+	} else {
+		props.AddComment("Written by osrs-launcher")
+	}
+
+	props.Set("JX_CHARACTER_ID", creds.CharacterID)
+	props.Set("JX_SESSION_ID", creds.SessionID)
+	props.Set("JX_DISPLAY_NAME", creds.DisplayName)
+	// Tokens are never handed to the client, and stale ones must not linger.
+	props.Set("JX_REFRESH_TOKEN", "")
+	props.Set("JX_ACCESS_TOKEN", "")
+	return props.Bytes(), nil
+}
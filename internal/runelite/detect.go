@@ -0,0 +1,151 @@
+package runelite
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// Variant is how a RuneLite client was installed.
+type Variant string
+
+const (
+	VariantNative   Variant = "native"
+	VariantFlatpak  Variant = "flatpak"
+	VariantAppImage Variant = "appimage"
+	VariantJar      Variant = "jar"
+	// VariantCustom is a home directory set through RUNELITE_HOME, without a
+	// known client binary.
+	VariantCustom Variant = "custom"
+)
+
+// FlatpakID is the Flathub application id of RuneLite.
+const FlatpakID = "net.runelite.RuneLite"
+
+// Install is a RuneLite client found on this machine.
+type Install struct {
+	Variant Variant
+	// Path is the executable, AppImage or jar. For Flatpak it is the app
+	// directory.
+	Path string
+	// ConfigDir is the client's ".runelite" directory.
+	ConfigDir string
+}
+
+// CredentialsPath is where this client reads credentials.properties from.
+func (i Install) CredentialsPath() string {
+	return filepath.Join(i.ConfigDir, "credentials.properties")
+}
+
+// DefaultConfigDir is the ".runelite" directory of a client started without
+// any customization.
+func DefaultConfigDir() string {
+	return filepath.Join(homeDir(), ".runelite")
+}
+
+// Detect finds installed RuneLite clients. The result is ordered with the
+// most conventional installs first.
+func Detect() []Install {
+	home := homeDir()
+	var found []Install
+
+	if custom := os.Getenv("RUNELITE_HOME"); custom != "" {
+		found = append(found, Install{Variant: VariantCustom, ConfigDir: custom})
+	}
+
+	for _, name := range []string{"runelite", "RuneLite"} {
+		if path, err := exec.LookPath(name); err == nil {
+			found = append(found, Install{Variant: VariantNative, Path: path, ConfigDir: DefaultConfigDir()})
+		}
+	}
+
+	for _, dir := range []string{
+		filepath.Join(home, ".local/share/flatpak/app", FlatpakID),
+		filepath.Join("/var/lib/flatpak/app", FlatpakID),
+	} {
+		if isDir(dir) {
+			// The Flatpak persists the sandboxed ~/.runelite under its app data.
+			found = append(found, Install{
+				Variant:   VariantFlatpak,
+				Path:      dir,
+				ConfigDir: filepath.Join(home, ".var/app", FlatpakID, ".runelite"),
+			})
+			break
+		}
+	}
+
+	searchDirs := []string{
+		filepath.Join(home, "Applications"),
+		filepath.Join(home, "Downloads"),
+		filepath.Join(home, ".local/bin"),
+		filepath.Join(home, "bin"),
+		filepath.Join(home, ".local/share/runelite"),
+		"/opt",
+		"/opt/runelite",
+		"/usr/share/runelite",
+	}
+	for _, dir := range searchDirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, "[Rr]une[Ll]ite*.AppImage"))
+		sort.Strings(matches)
+		for _, m := range matches {
+			found = append(found, Install{Variant: VariantAppImage, Path: m, ConfigDir: DefaultConfigDir()})
+		}
+	}
+	for _, dir := range searchDirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, "[Rr]une[Ll]ite*.jar"))
+		sort.Strings(matches)
+		for _, m := range matches {
+			found = append(found, Install{Variant: VariantJar, Path: m, ConfigDir: DefaultConfigDir()})
+		}
+	}
+
+	return dedupe(found)
+}
+
+// ConfigDirs returns the distinct config directories of the installs, in
+// order.
+func ConfigDirs(installs []Install) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, i := range installs {
+		if !seen[i.ConfigDir] {
+			seen[i.ConfigDir] = true
+			dirs = append(dirs, i.ConfigDir)
+		}
+	}
+	return dirs
+}
+
+func dedupe(installs []Install) []Install {
+	seen := make(map[string]bool)
+	out := installs[:0]
+	for _, i := range installs {
+		key := string(i.Variant) + "\x00" + i.Path + "\x00" + i.ConfigDir
+		if i.Path != "" {
+			// Symlinked binaries show up under several names.
+			if resolved, err := filepath.EvalSymlinks(i.Path); err == nil {
+				key = string(i.Variant) + "\x00" + resolved + "\x00" + i.ConfigDir
+			}
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, i)
+	}
+	return out
+}
+
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return os.Getenv("HOME")
+	}
+	return home
+}
+
+func isDir(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.IsDir()
+}
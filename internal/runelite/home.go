@@ -0,0 +1,153 @@
+package runelite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// seedCopies are the entries of a ".runelite" directory copied into a new
+// isolated home, so every character starts with the user's settings and
+// plugins.
+var seedCopies = []string{
+	"settings.properties",
+	"profiles2",
+	"plugins",
+	"sideloaded-plugins",
+	"externalmanager",
+}
+
+// seedLinks are shared with the main ".runelite" directory instead of copied.
+// They are downloads the launcher manages and only ever adds to.
+var seedLinks = []string{
+	"repository2",
+}
+
+// SeedHome creates the ".runelite" directory configDir from the user's main
+// one at src. Credentials, logs and caches are not copied. It does nothing if
+// configDir already exists.
+func SeedHome(src, configDir string) error {
+	if _, err := os.Stat(configDir); err == nil {
+		return nil
+	}
+
+	// Build the home in a temporary directory so an interrupted seed is not
+	// mistaken for a finished one.
+	tmp := configDir + ".seeding"
+	_ = os.RemoveAll(tmp)
+	err := os.MkdirAll(tmp, 0o700)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, name := range seedCopies {
+		err = copyTree(filepath.Join(src, name), filepath.Join(tmp, name))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("copy %s: %w", name, err)
+		}
+	}
+	for _, name := range seedLinks {
+		target := filepath.Join(src, name)
+		if _, err := os.Stat(target); err != nil {
+			continue
+		}
+		err = os.Symlink(target, filepath.Join(tmp, name))
+		if err != nil {
+			return fmt.Errorf("link %s: %w", name, err)
+		}
+	}
+
+	return os.Rename(tmp, configDir)
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Command returns the command that starts this client with home as its
+// user.home. The JVM reads JAVA_TOOL_OPTIONS at startup, which reaches the
+// client JVM even when it is started by the RuneLite launcher. env is added
+// to the client's environment.
+func (i Install) Command(ctx context.Context, home string, env []string) (*exec.Cmd, error) {
+	homeOpt := "-Duser.home=" + home
+	javaOpts := strings.TrimSpace(os.Getenv("JAVA_TOOL_OPTIONS") + " " + homeOpt)
+
+	var cmd *exec.Cmd
+	switch i.Variant {
+	case VariantNative, VariantAppImage:
+		cmd = exec.CommandContext(ctx, i.Path)
+	case VariantJar:
+		cmd = exec.CommandContext(ctx, "java", homeOpt, "-jar", i.Path)
+	case VariantFlatpak:
+		args := []string{"run", "--filesystem=" + home, "--env=JAVA_TOOL_OPTIONS=" + javaOpts}
+		for _, e := range env {
+			args = append(args, "--env="+e)
+		}
+		args = append(args, FlatpakID)
+		cmd = exec.CommandContext(ctx, "flatpak", args...)
+	default:
+		return nil, fmt.Errorf("cannot start a %s install", i.Variant)
+	}
+
+	cmd.Env = append(os.Environ(), "JAVA_TOOL_OPTIONS="+javaOpts)
+	cmd.Env = append(cmd.Env, env...)
+	return cmd, nil
+}
+
+// Launchable returns the first install that can be started, preferring the
+// order Detect returns.
+func Launchable(installs []Install) (Install, bool) {
+	for _, i := range installs {
+		if i.Variant != VariantCustom {
+			return i, true
+		}
+	}
+	return Install{}, false
+}
@@ -0,0 +1,151 @@
+package runelite
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Environment variables the launcher sets on clients it starts, so running
+// clients can be traced back to a character.
+const (
+	EnvCharacter = "OSRS_LAUNCHER_CHARACTER"
+	EnvAccount   = "OSRS_LAUNCHER_ACCOUNT"
+)
+
+// Process is a running RuneLite client.
+type Process struct {
+	PID     int
+	Cmdline []string
+	// ConfigDir is the ".runelite" directory the client uses.
+	ConfigDir string
+	// Character and Account are set when the launcher started the client.
+	Character string
+	Account   string
+}
+
+// Running scans /proc for RuneLite clients, including the JVMs started by
+// the RuneLite launcher. Processes of other users whose environment cannot
+// be read are still reported, with the default config directory.
+func Running() ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var procs []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		raw, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil || len(raw) == 0 {
+			continue
+		}
+		args := splitNull(raw)
+		if !isRuneLite(args) {
+			continue
+		}
+
+		env := make(map[string]string)
+		if rawEnv, err := os.ReadFile(filepath.Join(dir, "environ")); err == nil {
+			for _, kv := range splitNull(rawEnv) {
+				k, v, _ := strings.Cut(kv, "=")
+				env[k] = v
+			}
+		}
+
+		procs = append(procs, Process{
+			PID:       pid,
+			Cmdline:   args,
+			ConfigDir: processConfigDir(args, env),
+			Character: env[EnvCharacter],
+			Account:   env[EnvAccount],
+		})
+	}
+	return procs, nil
+}
+
+// UsingConfigDir returns the processes that read from the config directory.
+func UsingConfigDir(procs []Process, configDir string) []Process {
+	var using []Process
+	for _, p := range procs {
+		if samePath(p.ConfigDir, configDir) {
+			using = append(using, p)
+		}
+	}
+	return using
+}
+
+func isRuneLite(args []string) bool {
+	exe := strings.ToLower(filepath.Base(args[0]))
+	// The launcher starts clients with arguments mentioning RuneLite, so it
+	// has to be excluded by name.
+	if strings.Contains(exe, "osrs-launcher") {
+		return false
+	}
+	if strings.Contains(exe, "runelite") {
+		return true
+	}
+	if exe != "java" && !strings.HasPrefix(exe, "java") {
+		return false
+	}
+	for _, arg := range args[1:] {
+		lower := strings.ToLower(arg)
+		if strings.Contains(lower, "runelite") {
+			return true
+		}
+	}
+	return false
+}
+
+func processConfigDir(args []string, env map[string]string) string {
+	home := ""
+	for _, arg := range args {
+		if v, ok := strings.CutPrefix(arg, "-Duser.home="); ok {
+			home = v
+		}
+	}
+	if home == "" {
+		// JAVA_TOOL_OPTIONS is how launched clients get their home.
+		for _, opt := range strings.Fields(env["JAVA_TOOL_OPTIONS"]) {
+			if v, ok := strings.CutPrefix(opt, "-Duser.home="); ok {
+				home = v
+			}
+		}
+	}
+	if home != "" {
+		return filepath.Join(home, ".runelite")
+	}
+	if env["FLATPAK_ID"] == FlatpakID {
+		return filepath.Join(homeDir(), ".var/app", FlatpakID, ".runelite")
+	}
+	if env["HOME"] != "" {
+		return filepath.Join(env["HOME"], ".runelite")
+	}
+	return DefaultConfigDir()
+}
+
+func splitNull(b []byte) []string {
+	b = bytes.TrimRight(b, "\x00")
+	parts := bytes.Split(b, []byte{0})
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		out = append(out, string(p))
+	}
+	return out
+}
+
+func samePath(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && ra == rb
+}
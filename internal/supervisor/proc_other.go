@@ -0,0 +1,37 @@
+//go:build !unix
+
+package supervisor
+
+import (
+	"os"
+	"os/exec"
+)
+
+// newProcessGroup does nothing, without process groups only the client
+// process itself is stopped.
+func newProcessGroup(cmd *exec.Cmd) {}
+
+// terminateGroup kills pid, there is no signal that asks it to exit.
+func terminateGroup(pid int) error {
+	return killGroup(pid)
+}
+
+func killGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
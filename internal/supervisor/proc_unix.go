@@ -0,0 +1,33 @@
+//go:build unix
+
+package supervisor
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// newProcessGroup puts the client in its own process group. Clients fork the
+// real game JVM, signaling the group stops both.
+func newProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateGroup asks the process group led by pid to exit.
+func terminateGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGTERM)
+}
+
+// killGroup kills the process group led by pid.
+func killGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,95 @@
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is an io.Writer that appends to Path and rotates it to
+// Path.1, Path.2, ... once it grows past MaxSize. Only MaxFiles old files are
+// kept.
+type RotatingFile struct {
+	Path     string
+	MaxSize  int64
+	MaxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		err := r.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize {
+		err := r.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(r.Path), 0o750)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.file = f
+	r.size = st.Size()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", r.Path, r.MaxFiles))
+	for i := r.MaxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.Path, i), fmt.Sprintf("%s.%d", r.Path, i+1))
+	}
+	if r.MaxFiles > 0 {
+		err = os.Rename(r.Path, r.Path+".1")
+	} else {
+		err = os.Remove(r.Path)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
@@ -0,0 +1,344 @@
+// Package supervisor runs game clients, captures their output and restarts
+// them when they crash. Every supervised client has a state file and a
+// pidfile in a shared state directory, so other launcher processes can list
+// and stop them.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/rs/zerolog/log"
+)
+
+type Status string
+
+const (
+	StatusRunning    Status = "running"
+	StatusRestarting Status = "restarting"
+	StatusStopped    Status = "stopped"
+	StatusCrashed    Status = "crashed"
+)
+
+// State is what a supervisor records about its client.
+type State struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Account       string    `json:"account"`
+	Status        Status    `json:"status"`
+	SupervisorPID int       `json:"supervisor_pid"`
+	PID           int       `json:"pid"`
+	StartedAt     time.Time `json:"started_at"`
+	StoppedAt     time.Time `json:"stopped_at,omitempty"`
+	Restarts      int       `json:"restarts"`
+	LastExit      string    `json:"last_exit,omitempty"`
+	LogFile       string    `json:"log_file"`
+}
+
+// Alive reports whether the supervisor that wrote the state still runs.
+// States left behind by a killed supervisor are not alive.
+func (s State) Alive() bool {
+	return processAlive(s.SupervisorPID)
+}
+
+// Supervisor runs a single client.
+type Supervisor struct {
+	// ID names the state files, Name and Account are informational.
+	ID      string
+	Name    string
+	Account string
+	// Command builds the client command. It is called again for every
+	// restart so it can refresh credentials.
+	Command func() (*exec.Cmd, error)
+	// StateDir holds the state and pid files.
+	StateDir string
+	// LogDir holds the rotating client logs.
+	LogDir string
+
+	// Restart restarts the client when it exits abnormally, at most
+	// MaxRestarts times in a row. Backoff doubles from MinBackoff up to
+	// MaxBackoff between attempts.
+	Restart     bool
+	MaxRestarts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+
+	mu    sync.Mutex
+	state State
+	logs  *RotatingFile
+	done  chan struct{}
+	err   error
+}
+
+// Start starts the client and supervises it in the background until ctx is
+// canceled, the client exits cleanly or it crashes too often.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.logs = &RotatingFile{
+		Path:     filepath.Join(s.LogDir, s.ID+".log"),
+		MaxSize:  10 << 20,
+		MaxFiles: 5,
+	}
+	s.state = State{
+		ID:            s.ID,
+		Name:          s.Name,
+		Account:       s.Account,
+		SupervisorPID: os.Getpid(),
+		LogFile:       s.logs.Path,
+	}
+	s.done = make(chan struct{})
+	_ = os.Remove(s.stopFile())
+
+	cmd, err := s.start()
+	if err != nil {
+		_ = s.logs.Close()
+		return err
+	}
+	go func() {
+		s.err = s.supervise(ctx, cmd)
+		_ = s.logs.Close()
+		close(s.done)
+	}()
+	return nil
+}
+
+// Wait blocks until supervision ends.
+func (s *Supervisor) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// LogFile is where the client output is written.
+func (s *Supervisor) LogFile() string {
+	return s.logs.Path
+}
+
+// PID returns the current client pid.
+func (s *Supervisor) PID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.PID
+}
+
+func (s *Supervisor) start() (*exec.Cmd, error) {
+	cmd, err := s.Command()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = s.logs
+	cmd.Stderr = s.logs
+	newProcessGroup(cmd)
+
+	_, _ = fmt.Fprintf(s.logs, "--- osrs-launcher: starting %s at %s\n", s.Name, time.Now().Format(time.RFC3339))
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	s.update(func(st *State) {
+		st.Status = StatusRunning
+		st.PID = cmd.Process.Pid
+		st.StartedAt = time.Now()
+		st.StoppedAt = time.Time{}
+	})
+	return cmd, nil
+}
+
+func (s *Supervisor) supervise(ctx context.Context, cmd *exec.Cmd) error {
+	backoff := s.MinBackoff
+	if backoff <= 0 {
+		backoff = 2 * time.Second
+	}
+	maxBackoff := s.MaxBackoff
+	if maxBackoff < backoff {
+		maxBackoff = time.Minute
+	}
+
+	failures := 0
+	for {
+		started := time.Now()
+		exitErr := wait(ctx, cmd)
+		stopped := s.stopRequested()
+		_, _ = fmt.Fprintf(s.logs, "--- osrs-launcher: %s exited at %s: %v\n", s.Name, time.Now().Format(time.RFC3339), exitText(exitErr))
+
+		status := StatusStopped
+		if exitErr != nil && !stopped && ctx.Err() == nil {
+			status = StatusCrashed
+		}
+		s.update(func(st *State) {
+			st.Status = status
+			st.StoppedAt = time.Now()
+			st.LastExit = exitText(exitErr)
+		})
+		if status != StatusCrashed {
+			s.cleanup()
+			return nil
+		}
+
+		log.Warn().Err(exitErr).Str("character", s.Name).Msg("Client exited abnormally")
+		// A client that ran for a while is healthy, start the backoff over.
+		if time.Since(started) > 10*maxBackoff {
+			failures = 0
+			backoff = s.MinBackoff
+			if backoff <= 0 {
+				backoff = 2 * time.Second
+			}
+		}
+		failures++
+		if !s.Restart || (s.MaxRestarts > 0 && failures > s.MaxRestarts) {
+			s.cleanup()
+			return fmt.Errorf("%s exited: %s", s.Name, exitText(exitErr))
+		}
+
+		s.update(func(st *State) { st.Status = StatusRestarting })
+		log.Info().Str("character", s.Name).Dur("backoff", backoff).Msg("Restarting client")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			s.update(func(st *State) { st.Status = StatusStopped })
+			s.cleanup()
+			return nil
+		}
+		backoff = min(backoff*2, maxBackoff)
+
+		var err error
+		cmd, err = s.start()
+		if err != nil {
+			s.update(func(st *State) {
+				st.Status = StatusCrashed
+				st.LastExit = err.Error()
+			})
+			s.cleanup()
+			return fmt.Errorf("restart %s: %w", s.Name, err)
+		}
+		s.update(func(st *State) { st.Restarts++ })
+	}
+}
+
+// wait waits for the client, terminating its process group if ctx ends.
+func wait(ctx context.Context, cmd *exec.Cmd) error {
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+	}
+
+	pgid := cmd.Process.Pid
+	_ = terminateGroup(pgid)
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(10 * time.Second):
+		_ = killGroup(pgid)
+		return <-exited
+	}
+}
+
+func exitText(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
+
+func (s *Supervisor) update(fn func(st *State)) {
+	s.mu.Lock()
+	fn(&s.state)
+	st := s.state
+	s.mu.Unlock()
+
+	err := config.File(s.stateFile()).WriteJSON(st)
+	if err != nil {
+		log.Error().Err(err).Str("character", s.Name).Msg("writing supervisor state")
+	}
+	if st.Status == StatusRunning {
+		err = config.File(s.pidFile()).Write(strconv.Itoa(st.PID))
+		if err != nil {
+			log.Error().Err(err).Str("character", s.Name).Msg("writing pidfile")
+		}
+	}
+}
+
+// cleanup removes the pidfile once the client is gone. The state file is
+// kept so ps can show how the client ended.
+func (s *Supervisor) cleanup() {
+	_ = os.Remove(s.pidFile())
+	_ = os.Remove(s.stopFile())
+}
+
+func (s *Supervisor) stateFile() string { return filepath.Join(s.StateDir, s.ID+".json") }
+func (s *Supervisor) pidFile() string   { return filepath.Join(s.StateDir, s.ID+".pid") }
+func (s *Supervisor) stopFile() string  { return filepath.Join(s.StateDir, s.ID+".stop") }
+
+func (s *Supervisor) stopRequested() bool {
+	return config.File(s.stopFile()).Exists()
+}
+
+// List returns the state of every client that was supervised from stateDir.
+func List(stateDir string) ([]State, error) {
+	matches, err := filepath.Glob(filepath.Join(stateDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, 0, len(matches))
+	for _, m := range matches {
+		var st State
+		err := config.File(m).ReadJSON(&st)
+		if err != nil {
+			log.Warn().Err(err).Str("file", m).Msg("skipping unreadable supervisor state")
+			continue
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// ErrNotRunning is returned by Stop for clients that are not running.
+var ErrNotRunning = errors.New("client is not running")
+
+// Stop asks the supervisor of a client to stop it without restarting. name
+// matches a character id or display name.
+func Stop(stateDir string, name string) (State, error) {
+	states, err := List(stateDir)
+	if err != nil {
+		return State{}, err
+	}
+
+	for _, st := range states {
+		if st.ID != name && !strings.EqualFold(st.Name, name) {
+			continue
+		}
+		pidData, err := config.File(filepath.Join(stateDir, st.ID+".pid")).Read()
+		if err != nil {
+			return st, ErrNotRunning
+		}
+		pid, err := strconv.Atoi(pidData)
+		if err != nil || !processAlive(pid) {
+			return st, ErrNotRunning
+		}
+
+		// The supervisor checks for this file before deciding to restart.
+		err = config.File(filepath.Join(stateDir, st.ID+".stop")).Write(time.Now().Format(time.RFC3339))
+		if err != nil {
+			return st, fmt.Errorf("requesting stop: %w", err)
+		}
+		err = terminateGroup(pid)
+		if err != nil {
+			return st, fmt.Errorf("signal client: %w", err)
+		}
+		return st, nil
+	}
+	return State{}, fmt.Errorf("no supervised client named %q", name)
+}
@@ -0,0 +1,177 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSupervisor(t *testing.T, script string) *Supervisor {
+	dir := t.TempDir()
+	return &Supervisor{
+		ID:   "test",
+		Name: "test",
+		Command: func() (*exec.Cmd, error) {
+			return exec.Command("sh", "-c", script), nil
+		},
+		StateDir: filepath.Join(dir, "state"),
+		LogDir:   filepath.Join(dir, "logs"),
+	}
+}
+
+func TestSupervisorCleanExit(t *testing.T) {
+	s := testSupervisor(t, "echo hello")
+	s.Restart = true
+	err := s.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Wait()
+	if err != nil {
+		t.Fatalf("clean exit returned %v", err)
+	}
+
+	states, err := List(s.StateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Status != StatusStopped || states[0].Restarts != 0 {
+		t.Errorf("got states %+v", states)
+	}
+	if _, err := os.Stat(s.pidFile()); !os.IsNotExist(err) {
+		t.Errorf("pidfile left behind: %v", err)
+	}
+	logs, err := os.ReadFile(s.LogFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(logs), "hello\n") {
+		t.Errorf("client output missing from %q", logs)
+	}
+}
+
+func TestSupervisorRestarts(t *testing.T) {
+	tests := []struct {
+		name        string
+		restart     bool
+		maxRestarts int
+		restarts    int
+		// minElapsed is the sum of the backoffs, which double from 20ms
+		// up to 40ms.
+		minElapsed time.Duration
+	}{
+		{name: "no restart", restart: false, maxRestarts: 3, restarts: 0},
+		{name: "one", restart: true, maxRestarts: 1, restarts: 1, minElapsed: 20 * time.Millisecond},
+		{name: "capped backoff", restart: true, maxRestarts: 3, restarts: 3, minElapsed: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSupervisor(t, "exit 3")
+			s.Restart = tt.restart
+			s.MaxRestarts = tt.maxRestarts
+			s.MinBackoff = 20 * time.Millisecond
+			s.MaxBackoff = 40 * time.Millisecond
+
+			start := time.Now()
+			err := s.Start(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Wait()
+			if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+				t.Fatalf("got %v, want the exit status", err)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("gave up after %s, want at least %s of backoff", elapsed, tt.minElapsed)
+			}
+
+			states, err := List(s.StateDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(states) != 1 || states[0].Status != StatusCrashed || states[0].Restarts != tt.restarts {
+				t.Errorf("got states %+v, want %d restarts", states, tt.restarts)
+			}
+		})
+	}
+}
+
+func TestSupervisorCancel(t *testing.T) {
+	s := testSupervisor(t, "sleep 30")
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.pidFile()); err != nil {
+		t.Fatalf("no pidfile while running: %v", err)
+	}
+	cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("canceled supervision returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client was not stopped")
+	}
+	states, err := List(s.StateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Status != StatusStopped {
+		t.Errorf("got states %+v", states)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.log")
+	r := &RotatingFile{Path: path, MaxSize: 10, MaxFiles: 2}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		_, err := r.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		path:        "dddddddd\n",
+		path + ".1": "cccccccc\n",
+		path + ".2": "bbbbbbbb\n",
+	}
+	for file, content := range want {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: got %q, want %q", filepath.Base(file), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept more than MaxFiles old files: %v", err)
+	}
+
+	// Reopening appends to the current file.
+	r = &RotatingFile{Path: path, MaxSize: 100, MaxFiles: 2}
+	_, err = r.Write([]byte("eeee\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Close()
+	got, _ := os.ReadFile(path)
+	if string(got) != "dddddddd\neeee\n" {
+		t.Errorf("got %q after reopening", got)
+	}
+}
@@ -0,0 +1,160 @@
+// Package totp generates time-based one-time passwords (RFC 6238) like the
+// authenticator apps Jagex accounts use for two-factor logins.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key is an authenticator seed with its parameters.
+type Key struct {
+	Secret    []byte
+	Digits    int
+	Period    time.Duration
+	Algorithm string
+}
+
+// Parse reads a seed as authenticator apps take it: a base32 secret, with
+// any spaces, dashes or padding, or an otpauth:// URI from a QR code.
+// Secrets default to 6 digits every 30 seconds with SHA1.
+func Parse(seed string) (Key, error) {
+	seed = strings.TrimSpace(seed)
+	key := Key{Digits: 6, Period: 30 * time.Second, Algorithm: "SHA1"}
+	if strings.HasPrefix(seed, "otpauth://") {
+		return parseURI(seed, key)
+	}
+	secret, err := decodeSecret(seed)
+	if err != nil {
+		return Key{}, err
+	}
+	key.Secret = secret
+	return key, nil
+}
+
+func parseURI(raw string, key Key) (Key, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Key{}, fmt.Errorf("otpauth uri: %w", err)
+	}
+	if u.Host != "totp" {
+		return Key{}, fmt.Errorf("otpauth uri is %q, only totp is supported", u.Host)
+	}
+	q := u.Query()
+	key.Secret, err = decodeSecret(q.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+	if v := q.Get("digits"); v != "" {
+		key.Digits, err = strconv.Atoi(v)
+		if err != nil || key.Digits < 6 || key.Digits > 8 {
+			return Key{}, fmt.Errorf("otpauth uri: bad digits %q", v)
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			return Key{}, fmt.Errorf("otpauth uri: bad period %q", v)
+		}
+		key.Period = time.Duration(seconds) * time.Second
+	}
+	if v := q.Get("algorithm"); v != "" {
+		key.Algorithm = strings.ToUpper(v)
+		if _, err := newHash(key.Algorithm); err != nil {
+			return Key{}, err
+		}
+	}
+	return key, nil
+}
+
+func decodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(s))
+	if s == "" {
+		return nil, fmt.Errorf("empty secret")
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("secret is not base32: %w", err)
+	}
+	if len(secret) < 10 {
+		// RFC 4226 asks for 128 bits, but 80 bit secrets are common.
+		return nil, fmt.Errorf("secret is too short, it should be at least 16 characters")
+	}
+	return secret, nil
+}
+
+func newHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+// Validate reports whether codes can be generated with the key. Keys from
+// Parse are always valid.
+func (k Key) Validate() error {
+	if len(k.Secret) == 0 {
+		return fmt.Errorf("empty secret")
+	}
+	if k.Digits < 6 || k.Digits > 8 {
+		return fmt.Errorf("bad digits %d, want 6 to 8", k.Digits)
+	}
+	if k.Period < time.Second {
+		return fmt.Errorf("bad period %s, want at least a second", k.Period)
+	}
+	_, err := newHash(k.Algorithm)
+	return err
+}
+
+// Code returns the code at t.
+func (k Key) Code(t time.Time) (string, error) {
+	err := k.Validate()
+	if err != nil {
+		return "", err
+	}
+	newHash, err := newHash(k.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	counter := uint64(t.Unix()) / uint64(k.Period/time.Second)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(newHash, k.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, bin%mod), nil
+}
+
+// Remaining is how long the code at t stays valid, 0 for a key without a
+// valid period.
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period / time.Second)
+	if period <= 0 {
+		return 0
+	}
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
@@ -0,0 +1,129 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRFC6238 checks the test vectors of RFC 6238 Appendix B.
+func TestRFC6238(t *testing.T) {
+	secrets := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	tests := []struct {
+		unix  int64
+		codes map[string]string
+	}{
+		{59, map[string]string{"SHA1": "94287082", "SHA256": "46119246", "SHA512": "90693936"}},
+		{1111111109, map[string]string{"SHA1": "07081804", "SHA256": "68084774", "SHA512": "25091201"}},
+		{1111111111, map[string]string{"SHA1": "14050471", "SHA256": "67062674", "SHA512": "99943326"}},
+		{1234567890, map[string]string{"SHA1": "89005924", "SHA256": "91819424", "SHA512": "93441116"}},
+		{2000000000, map[string]string{"SHA1": "69279037", "SHA256": "90698825", "SHA512": "38618901"}},
+		{20000000000, map[string]string{"SHA1": "65353130", "SHA256": "77737706", "SHA512": "47863826"}},
+	}
+	for _, tt := range tests {
+		for algorithm, want := range tt.codes {
+			key := Key{Secret: []byte(secrets[algorithm]), Digits: 8, Period: 30 * time.Second, Algorithm: algorithm}
+			got, err := key.Code(time.Unix(tt.unix, 0))
+			if err != nil || got != want {
+				t.Errorf("%s at %d: got %s, %v, want %s", algorithm, tt.unix, got, err, want)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	rfcSecret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		name string
+		seed string
+		want Key
+		err  string
+	}{
+		{name: "base32", seed: rfcSecret, want: Key{Digits: 6, Period: 30 * time.Second, Algorithm: "SHA1"}},
+		{name: "grouped lower case", seed: " " + strings.ToLower(rfcSecret[:4]+" "+rfcSecret[4:8]+"-"+rfcSecret[8:]) + " ",
+			want: Key{Digits: 6, Period: 30 * time.Second, Algorithm: "SHA1"}},
+		{name: "uri defaults", seed: "otpauth://totp/Jagex:me?secret=" + rfcSecret + "&issuer=Jagex",
+			want: Key{Digits: 6, Period: 30 * time.Second, Algorithm: "SHA1"}},
+		{name: "uri parameters", seed: "otpauth://totp/Jagex:me?secret=" + rfcSecret + "&digits=8&period=60&algorithm=sha256",
+			want: Key{Digits: 8, Period: time.Minute, Algorithm: "SHA256"}},
+		{name: "empty", seed: "  ", err: "empty secret"},
+		{name: "not base32", seed: "JBSWY3DPEHPK3PX1", err: "not base32"},
+		{name: "too short", seed: "JBSWY3DP", err: "too short"},
+		{name: "hotp", seed: "otpauth://hotp/Jagex:me?secret=" + rfcSecret, err: "only totp"},
+		{name: "bad digits", seed: "otpauth://totp/x?secret=" + rfcSecret + "&digits=4", err: "bad digits"},
+		{name: "bad period", seed: "otpauth://totp/x?secret=" + rfcSecret + "&period=0", err: "bad period"},
+		{name: "bad algorithm", seed: "otpauth://totp/x?secret=" + rfcSecret + "&algorithm=MD5", err: "unsupported algorithm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := Parse(tt.seed)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(key.Secret) != "12345678901234567890" {
+				t.Errorf("got secret %q", key.Secret)
+			}
+			if key.Digits != tt.want.Digits || key.Period != tt.want.Period || key.Algorithm != tt.want.Algorithm {
+				t.Errorf("got %d digits, %s, %s, want %+v", key.Digits, key.Period, key.Algorithm, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeInvalidKey(t *testing.T) {
+	valid := Key{Secret: []byte("12345678901234567890"), Digits: 6, Period: 30 * time.Second, Algorithm: "SHA1"}
+	tests := []struct {
+		name string
+		edit func(k *Key)
+		err  string
+	}{
+		{name: "no secret", edit: func(k *Key) { k.Secret = nil }, err: "empty secret"},
+		{name: "zero period", edit: func(k *Key) { k.Period = 0 }, err: "bad period"},
+		{name: "sub second period", edit: func(k *Key) { k.Period = time.Millisecond }, err: "bad period"},
+		{name: "digits", edit: func(k *Key) { k.Digits = 12 }, err: "bad digits"},
+		{name: "algorithm", edit: func(k *Key) { k.Algorithm = "MD5" }, err: "unsupported algorithm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := valid
+			tt.edit(&key)
+			_, err := key.Code(time.Unix(59, 0))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+	if got := (Key{}).Remaining(time.Unix(59, 0)); got != 0 {
+		t.Errorf("got %s remaining without a period", got)
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	key := Key{Period: 30 * time.Second}
+	tests := []struct {
+		unix int64
+		want time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 29 * time.Second},
+		{29, time.Second},
+		{30, 30 * time.Second},
+		{59, time.Second},
+	}
+	for _, tt := range tests {
+		if got := key.Remaining(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("at %d: got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
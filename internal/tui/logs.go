@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// LogWriter feeds log lines to the dashboard's log pane. Set it as the
+// output of the logger while the dashboard runs, lines are dropped rather
+// than blocking when the dashboard falls behind.
+type LogWriter struct {
+	mu    sync.Mutex
+	buf   []byte
+	lines chan string
+}
+
+func NewLogWriter() *LogWriter {
+	return &LogWriter{lines: make(chan string, 256)}
+}
+
+func (w *LogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := strings.TrimRight(string(w.buf[:idx]), "\r")
+		w.buf = w.buf[idx+1:]
+		select {
+		case w.lines <- line:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+type logMsg string
+
+func (w *LogWriter) next() tea.Cmd {
+	return func() tea.Msg {
+		return logMsg(<-w.lines)
+	}
+}
@@ -0,0 +1,404 @@
+// Package tui is a full screen dashboard of the saved accounts, their
+// characters and session health, with hotkeys for the common actions.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/api"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// UseFunc makes a character the one RuneLite logs in as.
+type UseFunc func(ctx context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error
+
+type Dashboard struct {
+	Root   config.Root
+	Keeper *keeper.Keeper
+	Logs   *LogWriter
+	// Use and Launch are optional, their hotkeys do nothing without them.
+	Use    UseFunc
+	Launch api.Launcher
+}
+
+// Run shows the dashboard until the user quits or ctx is canceled.
+func (d *Dashboard) Run(ctx context.Context) error {
+	m := &model{d: d, ctx: ctx, logLines: make([]string, 0, maxLogLines)}
+	_, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+const (
+	maxLogLines    = 200
+	reloadInterval = 2 * time.Second
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true).Padding(0, 1)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	mutedStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	paneStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	stateStyles   = map[keeper.State]lipgloss.Style{
+		keeper.StateOK:           lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+		keeper.StateNeedsLogin:   lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		keeper.StateNeedsConsent: lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		keeper.StateError:        lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+	}
+)
+
+const help = "↑/↓ move · r refresh · R refresh all · u use · l launch · d delete · q quit"
+
+// row is a line of the account list, an account or one of its characters.
+type row struct {
+	account   int
+	character int // -1 for the account itself
+}
+
+type model struct {
+	d   *Dashboard
+	ctx context.Context
+
+	width, height int
+	accounts      []api.Account
+	rows          []row
+	cursor        int
+
+	status        string
+	statusErr     bool
+	busy          int
+	pendingDelete string
+
+	logLines []string
+}
+
+type accountsMsg struct {
+	accounts []api.Account
+	err      error
+}
+
+type doneMsg struct {
+	what string
+	err  error
+}
+
+type tickMsg struct{}
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(m.reload(), m.d.Logs.next(), tick())
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(reloadInterval, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+// reload reads the accounts and the keeper's latest status, it never touches
+// the network.
+func (m *model) reload() tea.Cmd {
+	return func() tea.Msg {
+		accounts, err := m.d.Root.Accounts()
+		if err != nil {
+			return accountsMsg{err: err}
+		}
+		status := m.d.Keeper.Status()
+		out := make([]api.Account, 0, len(accounts))
+		for _, account := range accounts {
+			acct, err := api.Describe(account, status)
+			if err != nil {
+				return accountsMsg{err: err}
+			}
+			out = append(out, acct)
+		}
+		return accountsMsg{accounts: out}
+	}
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case accountsMsg:
+		if msg.err != nil {
+			m.setStatus(msg.err.Error(), true)
+			return m, nil
+		}
+		m.setAccounts(msg.accounts)
+	case tickMsg:
+		return m, tea.Batch(m.reload(), tick())
+	case logMsg:
+		m.logLines = append(m.logLines, string(msg))
+		if len(m.logLines) > maxLogLines {
+			m.logLines = m.logLines[len(m.logLines)-maxLogLines:]
+		}
+		return m, m.d.Logs.next()
+	case doneMsg:
+		m.busy--
+		if msg.err != nil {
+			m.setStatus(fmt.Sprintf("%s failed: %v", msg.what, msg.err), true)
+		} else {
+			m.setStatus(msg.what+" done", false)
+		}
+		return m, m.reload()
+	case tea.KeyMsg:
+		return m, m.key(msg)
+	}
+	return m, nil
+}
+
+func (m *model) key(msg tea.KeyMsg) tea.Cmd {
+	if m.pendingDelete != "" {
+		name := m.pendingDelete
+		m.pendingDelete = ""
+		if msg.String() != "y" {
+			m.setStatus("Delete canceled", false)
+			return nil
+		}
+		return m.run("Deleting "+name, func(context.Context) error {
+			return m.d.Root.Account(name).Delete()
+		})
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c", "esc":
+		return tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.rows)-1 {
+			m.cursor++
+		}
+	case "r":
+		if acct, ok := m.selectedAccount(); ok {
+			return m.run("Refreshing "+acct.Name, func(ctx context.Context) error {
+				st := m.d.Keeper.Check(ctx, m.d.Root.Account(acct.Name))
+				return stateErr(st)
+			})
+		}
+	case "R":
+		return m.run("Refreshing all accounts", func(ctx context.Context) error {
+			m.d.Keeper.CheckAll(ctx)
+			return nil
+		})
+	case "d":
+		if acct, ok := m.selectedAccount(); ok {
+			m.pendingDelete = acct.Name
+			m.setStatus(fmt.Sprintf("Delete the saved tokens of %s? y/n", acct.Name), false)
+		}
+	case "u":
+		if m.d.Use == nil {
+			return nil
+		}
+		return m.characterAction("Using", func(ctx context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error {
+			return m.d.Use(ctx, account, token, character)
+		})
+	case "l":
+		if m.d.Launch == nil {
+			return nil
+		}
+		return m.characterAction("Launching", func(ctx context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error {
+			_, err := m.d.Launch(ctx, account, token, character)
+			return err
+		})
+	}
+	return nil
+}
+
+// characterAction runs fn for the selected character, or the only character
+// of the selected account.
+func (m *model) characterAction(verb string, fn func(ctx context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error) tea.Cmd {
+	acct, ok := m.selectedAccount()
+	if !ok {
+		return nil
+	}
+	query := ""
+	if r := m.rows[m.cursor]; r.character >= 0 {
+		query = acct.Characters[r.character].ID
+	} else if len(acct.Characters) != 1 {
+		m.setStatus("Select a character first", true)
+		return nil
+	}
+
+	label := acct.Name
+	if query != "" {
+		label = acct.Characters[m.rows[m.cursor].character].DisplayName
+	}
+	return m.run(verb+" "+label, func(ctx context.Context) error {
+		account := m.d.Root.Account(acct.Name)
+		token, st := m.d.Keeper.Session(ctx, account)
+		if err := stateErr(st); err != nil {
+			return err
+		}
+		character, err := auth.FindCharacter(token.Characters, query)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, account, token, character)
+	})
+}
+
+func stateErr(st keeper.AccountStatus) error {
+	if st.State == keeper.StateOK {
+		return nil
+	}
+	return fmt.Errorf("%s: %s", st.State, st.Message)
+}
+
+// run does slow work off the UI loop and reports back with a doneMsg.
+func (m *model) run(what string, fn func(ctx context.Context) error) tea.Cmd {
+	m.busy++
+	m.setStatus(what+"…", false)
+	return func() tea.Msg {
+		return doneMsg{what: what, err: fn(m.ctx)}
+	}
+}
+
+func (m *model) setStatus(text string, isErr bool) {
+	m.status, m.statusErr = text, isErr
+}
+
+func (m *model) setAccounts(accounts []api.Account) {
+	// Keep the cursor on the same line across reloads.
+	var selected row
+	if m.cursor < len(m.rows) {
+		selected = m.rows[m.cursor]
+	}
+	var selectedName string
+	if selected.account < len(m.accounts) {
+		selectedName = m.accounts[selected.account].Name
+	}
+
+	m.accounts = accounts
+	m.rows = m.rows[:0]
+	m.cursor = 0
+	for ai, acct := range accounts {
+		if acct.Name == selectedName && selected.character < 0 {
+			m.cursor = len(m.rows)
+		}
+		m.rows = append(m.rows, row{account: ai, character: -1})
+		for ci := range acct.Characters {
+			if acct.Name == selectedName && ci == selected.character {
+				m.cursor = len(m.rows)
+			}
+			m.rows = append(m.rows, row{account: ai, character: ci})
+		}
+	}
+}
+
+func (m *model) selectedAccount() (api.Account, bool) {
+	if m.cursor >= len(m.rows) {
+		return api.Account{}, false
+	}
+	return m.accounts[m.rows[m.cursor].account], true
+}
+
+func (m *model) View() string {
+	if m.width == 0 {
+		return "Loading…"
+	}
+
+	header := titleStyle.Render("osrs-launcher") + mutedStyle.Render(help)
+	status := m.status
+	if m.busy > 0 && !strings.HasSuffix(status, "…") {
+		status += " …"
+	}
+	if m.statusErr {
+		status = errorStyle.Render(status)
+	}
+
+	// Split what is left between the accounts and the logs, borders take two
+	// lines each.
+	inner := m.width - 4
+	avail := m.height - lipgloss.Height(header) - 1 - 4
+	listHeight := avail * 3 / 5
+	logHeight := avail - listHeight
+	if listHeight < 1 || logHeight < 1 {
+		return header
+	}
+
+	list := paneStyle.Width(inner).Height(listHeight).Render(
+		lipgloss.NewStyle().MaxWidth(inner).Render(m.listView(listHeight)))
+	logs := paneStyle.Width(inner).Height(logHeight).Render(m.logView(logHeight, inner))
+	return lipgloss.JoinVertical(lipgloss.Left, header, list, logs, status)
+}
+
+func (m *model) listView(height int) string {
+	if len(m.rows) == 0 {
+		return mutedStyle.Render("No accounts, add one with 'osrs-launcher auth'.")
+	}
+
+	lines := make([]string, 0, len(m.rows))
+	for i, r := range m.rows {
+		acct := m.accounts[r.account]
+		var line string
+		if r.character < 0 {
+			state := acct.State
+			text := string(state)
+			if state == "" {
+				text = "unchecked"
+			}
+			style, ok := stateStyles[state]
+			if !ok {
+				style = mutedStyle
+			}
+			line = fmt.Sprintf("%-20s %s", acct.Name, style.Render(text))
+			if !acct.TokenExpiry.IsZero() {
+				line += mutedStyle.Render(fmt.Sprintf("  token %s", until(acct.TokenExpiry)))
+			}
+			if acct.Message != "" && state != keeper.StateOK {
+				line += mutedStyle.Render("  " + acct.Message)
+			}
+		} else {
+			line = "  └ " + acct.Characters[r.character].DisplayName
+		}
+		if i == m.cursor {
+			line = selectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	// Scroll so the cursor stays visible.
+	start := 0
+	if m.cursor >= height {
+		start = m.cursor - height + 1
+	}
+	end := start + height
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return strings.Join(lines[start:end], "\n")
+}
+
+func (m *model) logView(height, width int) string {
+	lines := m.logLines
+	if len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if runes := []rune(line); len(runes) > width {
+			line = string(runes[:width])
+		}
+		out = append(out, line)
+	}
+	return mutedStyle.Render(strings.Join(out, "\n"))
+}
+
+func until(t time.Time) string {
+	d := time.Until(t).Round(time.Minute)
+	if d < 0 {
+		return "expired"
+	}
+	return "expires in " + d.String()
+}
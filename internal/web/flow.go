@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+)
+
+// Flow is a login or consent in progress. The page polls it to show the URLs
+// to visit and whether a pasted redirect is expected.
+type Flow struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Account string `json:"account,omitempty"`
+	// Title and URL are the last URL the user was asked to visit.
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url,omitempty"`
+	// Waiting is the prompt of a redirect the flow is waiting for.
+	Waiting string `json:"waiting,omitempty"`
+	Done    bool   `json:"done"`
+	Error   string `json:"error,omitempty"`
+}
+
+// flow is the running side of a Flow. It is the auth.Prompter of the login
+// code, which blocks in ReadRedirect until the page posts the redirect.
+type flow struct {
+	mu        sync.Mutex
+	state     Flow
+	redirects chan string
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+var _ auth.Prompter = (*flow)(nil)
+
+func (f *flow) snapshot() Flow {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+func (f *flow) update(fn func(*Flow)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(&f.state)
+}
+
+func (f *flow) ShowURL(title, url string) error {
+	f.update(func(st *Flow) {
+		st.Title, st.URL = title, url
+	})
+	return nil
+}
+
+func (f *flow) ReadRedirect(title string) (string, error) {
+	f.update(func(st *Flow) { st.Waiting = title })
+	defer f.update(func(st *Flow) { st.Waiting = "" })
+
+	select {
+	case redirect := <-f.redirects:
+		return redirect, nil
+	case <-f.ctx.Done():
+		return "", f.ctx.Err()
+	}
+}
+
+func (f *flow) SelectAccount([]string, bool) (string, error) {
+	return "", fmt.Errorf("selecting an account is not supported in the web UI")
+}
+
+func (f *flow) SelectCharacter([]auth.JagexCharacter) (auth.JagexCharacter, error) {
+	return auth.JagexCharacter{}, fmt.Errorf("selecting a character is not supported in the web UI")
+}
+
+func (f *flow) Confirm(string) (bool, error) {
+	return false, fmt.Errorf("confirming is not supported in the web UI")
+}
+
+func (f *flow) SelectMany(string, []string) ([]string, error) {
+	return nil, fmt.Errorf("selecting several options is not supported in the web UI")
+}
+
+// paste hands a redirect to the flow if it is waiting for one.
+func (f *flow) paste(redirect string) error {
+	if f.snapshot().Waiting == "" {
+		return fmt.Errorf("the flow is not waiting for a redirect")
+	}
+	select {
+	case f.redirects <- redirect:
+		return nil
+	default:
+		return fmt.Errorf("a redirect was already pasted")
+	}
+}
@@ -0,0 +1,421 @@
+// Package web is a small browser UI for managing accounts. It is served on
+// localhost and every request must carry the random token the server was
+// started with.
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Emyrk/osrs-launcher/auth"
+	"github.com/Emyrk/osrs-launcher/config"
+	"github.com/Emyrk/osrs-launcher/internal/api"
+	"github.com/Emyrk/osrs-launcher/internal/keeper"
+	"github.com/coreos/go-oidc"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// TokenHeader carries the token on API requests, the page itself takes it as
+// the token query parameter.
+const TokenHeader = "X-Launcher-Token"
+
+// UseFunc makes a character the one RuneLite logs in as.
+type UseFunc func(ctx context.Context, account config.Account, token auth.JagexAccountAuth, character auth.JagexCharacter) error
+
+type Server struct {
+	Root   config.Root
+	Keeper *keeper.Keeper
+	Token  string
+	// Launch and Use are optional, the actions are refused without them.
+	Launch api.Launcher
+	Use    UseFunc
+	// Verifier returns the ID token verifier for logins. It is only called
+	// when one starts, so the UI works without reaching Jagex first.
+	Verifier func(ctx context.Context) (*oidc.IDTokenVerifier, error)
+
+	// ctx outlives requests, flows run until it is canceled.
+	ctx  context.Context
+	mu   sync.Mutex
+	flow *flow
+}
+
+func New(ctx context.Context, root config.Root, k *keeper.Keeper) (*Server, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		Root:   root,
+		Keeper: k,
+		Token:  token,
+		ctx:    ctx,
+		Verifier: func(ctx context.Context) (*oidc.IDTokenVerifier, error) {
+			provider, err := auth.JagexProvider(ctx, root.CacheDir())
+			if err != nil {
+				return nil, fmt.Errorf("getting provider: %w", err)
+			}
+			return auth.JagexVerifier(provider), nil
+		},
+	}, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// URL is the address to open the UI at.
+func (s *Server) URL(addr net.Addr) string {
+	return fmt.Sprintf("http://%s/?token=%s", addr.String(), s.Token)
+}
+
+type characterRequest struct {
+	Character string `json:"character"`
+}
+
+type redirectRequest struct {
+	Redirect string `json:"redirect"`
+}
+
+// Handler routes, all behind the token:
+//
+//	GET  /
+//	GET  /api/accounts
+//	POST /api/accounts/{account}/refresh
+//	POST /api/accounts/{account}/consent
+//	POST /api/accounts/{account}/use      {"character": ...}
+//	POST /api/accounts/{account}/launch   {"character": ...}
+//	POST /api/login
+//	GET  /api/flow
+//	POST /api/flow/redirect               {"redirect": ...}
+//	POST /api/flow/cancel
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The token is in the page URL, keep it out of caches and referrers.
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong token"))
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.URL.Path == "/":
+			allow(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				_, _ = w.Write(indexHTML)
+			})
+		case len(parts) == 2 && parts[0] == "api" && parts[1] == "accounts":
+			allow(w, r, http.MethodGet, s.accounts)
+		case len(parts) == 4 && parts[0] == "api" && parts[1] == "accounts":
+			allow(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+				s.accountAction(w, r, parts[2], parts[3])
+			})
+		case len(parts) == 2 && parts[0] == "api" && parts[1] == "login":
+			allow(w, r, http.MethodPost, s.login)
+		case len(parts) == 2 && parts[0] == "api" && parts[1] == "flow":
+			allow(w, r, http.MethodGet, s.currentFlow)
+		case len(parts) == 3 && parts[0] == "api" && parts[1] == "flow" && parts[2] == "redirect":
+			allow(w, r, http.MethodPost, s.pasteRedirect)
+		case len(parts) == 3 && parts[0] == "api" && parts[1] == "flow" && parts[2] == "cancel":
+			allow(w, r, http.MethodPost, s.cancelFlow)
+		default:
+			writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		}
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	got := r.Header.Get(TokenHeader)
+	if got == "" {
+		got = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) == 1
+}
+
+func allow(w http.ResponseWriter, r *http.Request, method string, h http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+	h(w, r)
+}
+
+func (s *Server) accounts(w http.ResponseWriter, _ *http.Request) {
+	accounts, err := s.Root.Accounts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	status := s.Keeper.Status()
+	out := make([]api.Account, 0, len(accounts))
+	for _, account := range accounts {
+		acct, err := api.Describe(account, status)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		out = append(out, acct)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) accountAction(w http.ResponseWriter, r *http.Request, name, action string) {
+	// Account names are directory names, never let them walk the tree.
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid account name"))
+		return
+	}
+	account := s.Root.Account(name)
+	if !account.Exists() {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no account %q", name))
+		return
+	}
+
+	switch action {
+	case "refresh":
+		writeJSON(w, http.StatusOK, s.Keeper.Check(r.Context(), account))
+	case "consent":
+		s.consent(w, account)
+	case "use", "launch":
+		var req characterRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %w", err))
+			return
+		}
+		token, st := s.Keeper.Session(r.Context(), account)
+		if st.State != keeper.StateOK {
+			writeError(w, http.StatusConflict, fmt.Errorf("%s: %s", st.State, st.Message))
+			return
+		}
+		character, err := auth.FindCharacter(token.Characters, req.Character)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		if action == "use" {
+			if s.Use == nil {
+				writeError(w, http.StatusNotImplemented, fmt.Errorf("switching characters is not supported"))
+				return
+			}
+			err = s.Use(r.Context(), account, token, character)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, api.Session{
+				Account:     account.Name(),
+				CharacterID: character.AccountID,
+				DisplayName: character.DisplayName,
+			})
+			return
+		}
+
+		if s.Launch == nil {
+			writeError(w, http.StatusNotImplemented, fmt.Errorf("launching is not supported"))
+			return
+		}
+		resp, err := s.Launch(r.Context(), account, token, character)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+	}
+}
+
+// login adds a new account.
+func (s *Server) login(w http.ResponseWriter, _ *http.Request) {
+	s.startFlow(w, "login", "", func(ctx context.Context, f *flow) error {
+		verifier, err := s.Verifier(ctx)
+		if err != nil {
+			return err
+		}
+		cfg := auth.JagexOAuthConfig()
+		token, err := auth.AuthenticateJagexAccount(ctx, cfg, f, auth.LoginOptions{})
+		if err != nil {
+			return fmt.Errorf("getting oauth token: %w", err)
+		}
+		displayName, err := token.Setup(ctx, cfg, verifier, f, auth.SetupOptions{})
+		if displayName.DisplayName != "" {
+			f.update(func(st *Flow) { st.Account = displayName.DisplayName })
+			saveErr := s.Root.Account(displayName.DisplayName).SaveToken(token)
+			if saveErr != nil {
+				return fmt.Errorf("saving token: %w", saveErr)
+			}
+		}
+		return err
+	})
+}
+
+// consent asks for a new game session consent for an account.
+func (s *Server) consent(w http.ResponseWriter, account config.Account) {
+	s.startFlow(w, "consent", account.Name(), func(ctx context.Context, f *flow) error {
+		verifier, err := s.Verifier(ctx)
+		if err != nil {
+			return err
+		}
+		unlock, err := s.Keeper.Lock(ctx, account)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		token, err := account.Token()
+		if err != nil {
+			return fmt.Errorf("loading token: %w", err)
+		}
+		// Keep the old session if no new one is made, it may still work.
+		oldSession := token.Session
+		token.Session, token.GameIDToken = "", ""
+		_, err = token.Setup(ctx, auth.JagexOAuthConfig(), verifier, f, auth.SetupOptions{})
+		if err != nil && token.Session == "" {
+			token.Session = oldSession
+		}
+		saveErr := account.SaveToken(&token)
+		if saveErr != nil {
+			return fmt.Errorf("saving token: %w", saveErr)
+		}
+		return err
+	})
+}
+
+// startFlow runs one login or consent at a time, both need the port 80
+// callback.
+func (s *Server) startFlow(w http.ResponseWriter, kind, account string, run func(ctx context.Context, f *flow) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flow != nil && !s.flow.snapshot().Done {
+		writeError(w, http.StatusConflict, fmt.Errorf("a %s is already in progress", s.flow.snapshot().Kind))
+		return
+	}
+	err := auth.TestPort80()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("the consent callback cannot listen on port 80: %w", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 15*time.Minute)
+	f := &flow{
+		state:     Flow{ID: uuid.NewString(), Kind: kind, Account: account},
+		redirects: make(chan string, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	s.flow = f
+	go func() {
+		defer cancel()
+		err := run(ctx, f)
+		if err != nil {
+			log.Error().Err(err).Str("flow", kind).Msg("Web flow failed")
+		} else {
+			log.Info().Str("flow", kind).Str("account", f.snapshot().Account).Msg("Web flow complete")
+		}
+		f.update(func(st *Flow) {
+			st.Done = true
+			if err != nil {
+				st.Error = err.Error()
+			}
+		})
+	}()
+	writeJSON(w, http.StatusAccepted, f.snapshot())
+}
+
+func (s *Server) currentFlow(w http.ResponseWriter, _ *http.Request) {
+	f := s.current()
+	if f == nil {
+		writeJSON(w, http.StatusOK, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, f.snapshot())
+}
+
+func (s *Server) pasteRedirect(w http.ResponseWriter, r *http.Request) {
+	var req redirectRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %w", err))
+		return
+	}
+	f := s.current()
+	if f == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("no login in progress"))
+		return
+	}
+	err = f.paste(strings.TrimSpace(req.Redirect))
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f.snapshot())
+}
+
+func (s *Server) cancelFlow(w http.ResponseWriter, _ *http.Request) {
+	f := s.current()
+	if f != nil {
+		f.cancel()
+	}
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) current() *flow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flow
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, api.Error{Error: err.Error()})
+}
+
+// Serve serves the UI on l until ctx is canceled.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err := srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}